@@ -1,10 +1,15 @@
 package concurrent
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang/concurrent/clock"
+	"golang/concurrent/pool"
 )
 
 /*
@@ -17,48 +22,78 @@ import (
 type ReusableObj struct {
 	val int
 }
-type ObjPool struct {
-	bufChan chan *ReusableObj
+
+// ObjPool 最初是固定大小的 channel，GetObj 超时返回错误，ReleaseObj 溢出时返回错误
+// 改为 pool.Pool 之后由 Get、Resource.Release 代替：按需创建、限制总数、借出前检查、统计
+type ObjPool = pool.Pool[*ReusableObj]
+
+// ObjPoolOption NewObjPool 的选项
+type ObjPoolOption func(*pool.Config[*ReusableObj])
+
+// WithClock Get 等待计时使用的时钟，测试时可以使用 clock.Fake
+func WithClock(c clock.Clock) ObjPoolOption {
+	return func(cfg *pool.Config[*ReusableObj]) { cfg.Clock = c }
 }
 
-func NewObjPool(num int) *ObjPool {
-	ch := make(chan *ReusableObj, num)
-	for i := 0; i < num; i++ {
-		//ch <- new(ReusableObj)
-		ch <- &ReusableObj{i}
+// NewObjPool 预先创建 num 个对象，与原来的 GetObj 一样，Get 最多等待 timeout
+func NewObjPool(num int, timeout time.Duration, opts ...ObjPoolOption) *ObjPool {
+	var n atomic.Int32
+	cfg := pool.Config[*ReusableObj]{
+		New: func(context.Context) (*ReusableObj, error) {
+			return &ReusableObj{int(n.Add(1) - 1)}, nil
+		},
+		MinIdle: num,
+		MaxOpen: num,
+		MaxWait: timeout,
 	}
-	return &ObjPool{ch}
-}
-func (p *ObjPool) GetObj(timeout time.Duration) (*ReusableObj, error) {
-	select {
-	case ret := <-p.bufChan:
-		return ret, nil
-	case <-time.After(timeout):
-		return nil, errors.New("time out")
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-}
-func (p *ObjPool) ReleaseObj(obj *ReusableObj) error {
-	select {
-	case p.bufChan <- obj:
-		return nil
-	default:
-		return errors.New("overflow")
+	p, err := pool.New(cfg)
+	if err != nil {
+		panic(err)
 	}
+	return p
 }
+
 func TestObjPool(t *testing.T) {
-	pool := NewObjPool(10)
-	//if err := pool.ReleaseObj(&ReusableObj{}); err != nil {
-	//	t.Error(err)
-	//}	// overflow
+	p := NewObjPool(10, time.Second)
+	defer p.Close()
 	for i := 0; i < 11; i++ {
-		if o, err := pool.GetObj(time.Second * 1); err != nil {
+		if o, err := p.Get(context.Background()); err != nil {
 			t.Error(err)
 		} else {
-			fmt.Printf("%d,%T,%d\n", i, o, o.val)
-			if err = pool.ReleaseObj(o); err != nil {
-				t.Error(err)
-			}
+			fmt.Printf("%d,%T,%d\n", i, o.Value(), o.Value().val)
+			o.Release()
 		}
 	}
-	fmt.Println("Done")
+	fmt.Printf("Done %+v\n", p.Stats())
+}
+
+func TestObjPoolTimeout(t *testing.T) {
+	c := clock.NewFake(time.Now())
+	p := NewObjPool(1, time.Second, WithClock(c))
+	defer p.Close()
+	o, _ := p.Get(context.Background())
+
+	errc := make(chan error)
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+	c.BlockUntil(2) // 后台维护的 ticker 和 Get 的等待
+	c.Advance(time.Second)
+	if err := <-errc; !errors.Is(err, pool.ErrWaitTimeout) {
+		t.Fatalf("Get from empty pool = %v", err)
+	}
+
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+	c.BlockUntil(2)
+	o.Release()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
 }
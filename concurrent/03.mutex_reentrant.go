@@ -0,0 +1,332 @@
+package concurrent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/petermattis/goid"
+
+	"golang/concurrent/internal/stack"
+)
+
+/*
+可重入锁（递归锁）
+	03.mutex_03.go 中的 RecursiveMutex（goroutine id）和 TokenRecursiveMutex（token）共用这里的实现
+	ReentrantMutex 实现了 Locker 接口，并提供 TryLock、LockContext
+	ReentrantRWMutex 读写锁版本：
+		写锁可重入，持有写锁时可以再加读锁
+		读锁可重入，已持有读锁的 goroutine 再次 RLock 不会被等待的 writer 阻塞，避免 RWMutexCircleWaite 中的死锁
+		持有读锁再请求写锁（锁升级）一定会死锁，直接 panic
+误用时的诊断信息
+	panic 或返回的 *LockError 中包含请求者和持有者的 goroutine id，以及持有者获取锁时的调用栈
+	获取锁时只记录 PC（runtime.Callers），出错时才解析成调用栈，正常路径的开销很小
+*/
+
+// LockError 可重入锁误用或者获取锁失败时的错误
+type LockError struct {
+	Op        string  // lock、unlock、rlock、runlock
+	Goroutine int64   // 请求者
+	Owner     int64   // 持有者，0 表示未被持有
+	Stack     string  // 持有者获取锁时的调用栈
+	Readers   []int64 // 读写锁的读锁持有者
+	Reason    string
+	Err       error // 获取锁失败时 ctx 的错误
+}
+
+func (e *LockError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "reentrant mutex: %s by goroutine %d", e.Op, e.Goroutine)
+	if e.Reason != "" {
+		b.WriteString(": " + e.Reason)
+	}
+	if e.Err != nil {
+		b.WriteString(": " + e.Err.Error())
+	}
+	if e.Owner != 0 {
+		fmt.Fprintf(&b, ", held by goroutine %d", e.Owner)
+		if e.Stack != "" {
+			b.WriteString(", acquired at:\n" + e.Stack)
+		}
+	}
+	if len(e.Readers) > 0 {
+		fmt.Fprintf(&b, ", read locked by goroutines %v", e.Readers)
+	}
+	return b.String()
+}
+
+func (e *LockError) Unwrap() error { return e.Err }
+
+// ==========ReentrantMutex==========
+
+// reentrantLock 以 owner id 标识持有者的可重入锁
+// ReentrantMutex 以 goroutine id 作为 owner，TokenRecursiveMutex 以调用者传入的 token 作为 owner
+type reentrantLock struct {
+	mu        sync.Mutex    // 保护下面的字段
+	sem       chan struct{} // 容量为 1，放入一个元素表示加锁
+	owner     int64
+	recursion int32
+	pcs       []uintptr // 持有者获取锁时的调用栈
+}
+
+func (l *reentrantLock) lock(ctx context.Context, op string, owner int64, block bool) error {
+	l.mu.Lock()
+	if l.recursion > 0 && l.owner == owner { // 重入
+		l.recursion++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.sem == nil { // 零值可用
+		l.sem = make(chan struct{}, 1)
+	}
+	sem := l.sem
+	l.mu.Unlock()
+
+	if block {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return l.error(op, owner, "", ctx.Err())
+		}
+	} else {
+		select {
+		case sem <- struct{}{}:
+		default:
+			return l.error(op, owner, "", nil)
+		}
+	}
+
+	l.mu.Lock()
+	l.owner = owner
+	l.recursion = 1
+	l.pcs = stack.Callers(3)
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *reentrantLock) unlock(op string, owner int64) {
+	l.mu.Lock()
+	if l.sem == nil || l.recursion == 0 {
+		l.mu.Unlock()
+		panic(&LockError{Op: op, Goroutine: owner, Reason: "unlock of unlocked mutex"})
+	}
+	if l.owner != owner { // 非持有者释放锁
+		err := &LockError{Op: op, Goroutine: owner, Owner: l.owner, Stack: stack.Format(l.pcs), Reason: "not the owner"}
+		l.mu.Unlock()
+		panic(err)
+	}
+	l.recursion--
+	if l.recursion == 0 { // 最后一次释放
+		l.owner = 0
+		l.pcs = nil
+		<-l.sem
+	}
+	l.mu.Unlock()
+}
+
+func (l *reentrantLock) error(op string, owner int64, reason string, err error) *LockError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := &LockError{Op: op, Goroutine: owner, Reason: reason, Err: err}
+	if l.recursion > 0 {
+		e.Owner = l.owner
+		e.Stack = stack.Format(l.pcs)
+	}
+	return e
+}
+
+// ReentrantMutex 以 goroutine id 标识持有者的可重入锁，实现了 Locker 接口，零值可用
+// 持有者可以多次 Lock，但必须调用相同次数的 Unlock；非持有者 Unlock 会 panic(*LockError)
+type ReentrantMutex struct {
+	l reentrantLock
+}
+
+// Lock 请求锁，持有者重入时直接返回
+func (m *ReentrantMutex) Lock() {
+	_ = m.l.lock(context.Background(), "lock", goid.Get(), true)
+}
+
+// TryLock 尝试获取锁，被其它 goroutine 持有时返回 false
+func (m *ReentrantMutex) TryLock() bool {
+	return m.l.lock(context.Background(), "trylock", goid.Get(), false) == nil
+}
+
+// LockContext 请求锁，直到获取到锁或 ctx 结束
+// 失败时返回 *LockError，包含当前持有者的信息，errors.Is(err, ctx.Err()) 成立
+func (m *ReentrantMutex) LockContext(ctx context.Context) error {
+	return m.l.lock(ctx, "lock", goid.Get(), true)
+}
+
+// Unlock 释放锁
+func (m *ReentrantMutex) Unlock() {
+	m.l.unlock("unlock", goid.Get())
+}
+
+// ==========ReentrantRWMutex==========
+
+// ReentrantRWMutex 以 goroutine id 标识持有者的可重入读写锁，零值可用
+type ReentrantRWMutex struct {
+	mu       sync.Mutex
+	writer   int64 // 持有写锁的 goroutine
+	wrec     int32 // 写锁重入次数
+	wpcs     []uintptr
+	readers  map[int64]int32 // 持有读锁的 goroutine 及其重入次数
+	wwaiting int             // 等待中的 writer，新来的 reader 需要等待它们
+	changed  chan struct{}   // 状态变化时 close 并替换，唤醒所有等待者
+}
+
+// Lock 请求写锁
+func (rw *ReentrantRWMutex) Lock() {
+	if err := rw.LockContext(context.Background()); err != nil {
+		panic(err) // 锁升级
+	}
+}
+
+// TryLock 尝试获取写锁
+func (rw *ReentrantRWMutex) TryLock() bool {
+	return rw.lock(context.Background(), false) == nil
+}
+
+// LockContext 请求写锁，直到获取到锁或 ctx 结束
+// 持有读锁的 goroutine 请求写锁一定会死锁，直接返回 *LockError
+func (rw *ReentrantRWMutex) LockContext(ctx context.Context) error {
+	return rw.lock(ctx, true)
+}
+
+func (rw *ReentrantRWMutex) lock(ctx context.Context, block bool) error {
+	gid := goid.Get()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.writer == gid { // 写锁重入
+		rw.wrec++
+		return nil
+	}
+	if rw.readers[gid] > 0 {
+		return rw.error("lock", gid, "upgrade from read lock would deadlock", nil)
+	}
+	rw.wwaiting++
+	for rw.writer != 0 || len(rw.readers) > 0 {
+		if !block {
+			rw.wwaiting--
+			return rw.error("trylock", gid, "", nil)
+		}
+		if err := rw.wait(ctx); err != nil {
+			rw.wwaiting--
+			rw.broadcast() // 可能挡住了新来的 reader
+			return rw.error("lock", gid, "", err)
+		}
+	}
+	rw.wwaiting--
+	rw.writer = gid
+	rw.wrec = 1
+	rw.wpcs = stack.Callers(3)
+	return nil
+}
+
+// Unlock 释放写锁，写锁期间获取的读锁保持不变（锁降级）
+func (rw *ReentrantRWMutex) Unlock() {
+	gid := goid.Get()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.writer != gid {
+		reason := "not the owner"
+		if rw.writer == 0 {
+			reason = "unlock of unlocked mutex"
+		}
+		panic(rw.error("unlock", gid, reason, nil))
+	}
+	rw.wrec--
+	if rw.wrec == 0 {
+		rw.writer = 0
+		rw.wpcs = nil
+		rw.broadcast()
+	}
+}
+
+// RLock 请求读锁
+func (rw *ReentrantRWMutex) RLock() {
+	_ = rw.RLockContext(context.Background())
+}
+
+// TryRLock 尝试获取读锁
+func (rw *ReentrantRWMutex) TryRLock() bool {
+	return rw.rlock(context.Background(), false) == nil
+}
+
+// RLockContext 请求读锁，直到获取到锁或 ctx 结束
+func (rw *ReentrantRWMutex) RLockContext(ctx context.Context) error {
+	return rw.rlock(ctx, true)
+}
+
+func (rw *ReentrantRWMutex) rlock(ctx context.Context, block bool) error {
+	gid := goid.Get()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	// 持有写锁或者读锁时直接重入，不需要等待其它 writer
+	for rw.writer != gid && rw.readers[gid] == 0 && (rw.writer != 0 || rw.wwaiting > 0) {
+		if !block {
+			return rw.error("tryrlock", gid, "", nil)
+		}
+		if err := rw.wait(ctx); err != nil {
+			return rw.error("rlock", gid, "", err)
+		}
+	}
+	if rw.readers == nil {
+		rw.readers = make(map[int64]int32)
+	}
+	rw.readers[gid]++
+	return nil
+}
+
+// RUnlock 释放读锁
+func (rw *ReentrantRWMutex) RUnlock() {
+	gid := goid.Get()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.readers[gid] == 0 {
+		panic(rw.error("runlock", gid, "goroutine does not hold a read lock", nil))
+	}
+	rw.readers[gid]--
+	if rw.readers[gid] == 0 {
+		delete(rw.readers, gid)
+		if len(rw.readers) == 0 {
+			rw.broadcast()
+		}
+	}
+}
+
+// wait 释放 rw.mu 等待状态变化，返回时重新持有 rw.mu
+func (rw *ReentrantRWMutex) wait(ctx context.Context) error {
+	if rw.changed == nil {
+		rw.changed = make(chan struct{})
+	}
+	changed := rw.changed
+	rw.mu.Unlock()
+	defer rw.mu.Lock()
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (rw *ReentrantRWMutex) broadcast() {
+	if rw.changed != nil {
+		close(rw.changed)
+		rw.changed = nil
+	}
+}
+
+// error 构造错误信息，需要持有 rw.mu
+func (rw *ReentrantRWMutex) error(op string, gid int64, reason string, err error) *LockError {
+	e := &LockError{Op: op, Goroutine: gid, Reason: reason, Err: err, Owner: rw.writer}
+	if rw.writer != 0 {
+		e.Stack = stack.Format(rw.wpcs)
+	}
+	for id := range rw.readers {
+		e.Readers = append(e.Readers, id)
+	}
+	return e
+}
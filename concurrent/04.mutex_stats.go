@@ -0,0 +1,186 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+带统计信息的 Mutex
+	04.mutex_04.go 通过 unsafe 读取 sync.Mutex 的 state 字段，依赖运行时的内部布局，Go 版本升级后可能失效
+	StatsMutex 不读取内部字段，而是在 Lock/Unlock 外面记录：
+		获取次数、需要等待的次数、总等待时间和最大等待时间
+		持有时间的直方图
+		饥饿模式：与 sync.Mutex 的规则一致，等待者等待超过 1ms 进入饥饿模式，等待少于 1ms 或者没有等待者时退出
+	Stats 返回某一时刻的快照，各个字段分别原子地读取，不保证彼此完全一致
+*/
+
+// starvationThreshold 与 sync.Mutex 的 starvationThresholdNs 一致
+const starvationThreshold = time.Millisecond
+
+// HoldBuckets 持有时间直方图的上界，最后一个桶统计超过 1s 的持有
+var HoldBuckets = [...]time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// MutexStats StatsMutex 的统计快照
+type MutexStats struct {
+	Acquisitions      int64 // 获取锁的次数
+	Contended         int64 // 需要等待的次数
+	TotalWait         time.Duration
+	MaxWait           time.Duration
+	TotalHold         time.Duration
+	MaxHold           time.Duration
+	HoldHistogram     [len(HoldBuckets) + 1]int64 // HoldHistogram[i] 为持有时间落在 [HoldBuckets[i-1], HoldBuckets[i]) 的次数
+	StarvationEntries int64                       // 进入饥饿模式的次数
+	Waiters           int                         // 当前的等待者数量
+	Locked            bool
+	Starving          bool
+}
+
+// AvgWait 平均等待时间
+func (s MutexStats) AvgWait() time.Duration {
+	if s.Acquisitions == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Acquisitions)
+}
+
+// AvgHold 平均持有时间
+func (s MutexStats) AvgHold() time.Duration {
+	if s.Acquisitions == 0 {
+		return 0
+	}
+	return s.TotalHold / time.Duration(s.Acquisitions)
+}
+
+// StatsMutex 记录竞争情况的 Mutex，零值可用
+type StatsMutex struct {
+	mu         sync.Mutex
+	acquiredAt time.Time // 由持有者读写
+
+	waiters  atomic.Int32
+	locked   atomic.Bool
+	starving atomic.Bool
+
+	acquisitions      atomic.Int64
+	contended         atomic.Int64
+	totalWait         atomic.Int64
+	maxWait           atomic.Int64
+	totalHold         atomic.Int64
+	maxHold           atomic.Int64
+	holdHistogram     [len(HoldBuckets) + 1]atomic.Int64
+	starvationEntries atomic.Int64
+}
+
+// Lock 请求锁
+func (m *StatsMutex) Lock() {
+	if m.mu.TryLock() { // fast path，没有竞争
+		m.acquired(0)
+		return
+	}
+	start := time.Now() // 先计时再登记为等待者，WaiterCount 看到它时等待时间已经开始累计
+	m.waiters.Add(1)
+	m.mu.Lock()
+	wait := time.Since(start)
+	m.waiters.Add(-1)
+	m.contended.Add(1)
+	m.acquired(wait)
+}
+
+// TryLock 尝试获取锁
+func (m *StatsMutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	m.acquired(0)
+	return true
+}
+
+// Unlock 释放锁
+func (m *StatsMutex) Unlock() {
+	hold := time.Since(m.acquiredAt)
+	m.locked.Store(false)
+	m.mu.Unlock()
+
+	m.totalHold.Add(int64(hold))
+	storeMax(&m.maxHold, int64(hold))
+	i := 0
+	for i < len(HoldBuckets) && hold >= HoldBuckets[i] {
+		i++
+	}
+	m.holdHistogram[i].Add(1)
+}
+
+// acquired 获取到锁之后记录，需要持有锁
+func (m *StatsMutex) acquired(wait time.Duration) {
+	m.acquiredAt = time.Now()
+	m.locked.Store(true)
+	m.acquisitions.Add(1)
+	m.totalWait.Add(int64(wait))
+	storeMax(&m.maxWait, int64(wait))
+
+	switch {
+	case wait > starvationThreshold: // 等待超过 1ms 进入饥饿模式
+		if m.starving.CompareAndSwap(false, true) {
+			m.starvationEntries.Add(1)
+		}
+		if m.waiters.Load() == 0 { // 最后一个等待者，退出饥饿模式
+			m.starving.Store(false)
+		}
+	case wait > 0 || m.waiters.Load() == 0: // 等待少于 1ms，或者没有等待者，退出饥饿模式
+		m.starving.Store(false)
+	}
+}
+
+// WaiterCount 当前等待锁的 goroutine 数量
+func (m *StatsMutex) WaiterCount() int {
+	return int(m.waiters.Load())
+}
+
+// IsLocked 锁是否被持有
+func (m *StatsMutex) IsLocked() bool {
+	return m.locked.Load()
+}
+
+// IsStarving 锁是否处于饥饿模式
+func (m *StatsMutex) IsStarving() bool {
+	return m.starving.Load()
+}
+
+// Stats 统计快照
+func (m *StatsMutex) Stats() MutexStats {
+	s := MutexStats{
+		Acquisitions:      m.acquisitions.Load(),
+		Contended:         m.contended.Load(),
+		TotalWait:         time.Duration(m.totalWait.Load()),
+		MaxWait:           time.Duration(m.maxWait.Load()),
+		TotalHold:         time.Duration(m.totalHold.Load()),
+		MaxHold:           time.Duration(m.maxHold.Load()),
+		StarvationEntries: m.starvationEntries.Load(),
+		Waiters:           m.WaiterCount(),
+		Locked:            m.IsLocked(),
+		Starving:          m.IsStarving(),
+	}
+	for i := range m.holdHistogram {
+		s.HoldHistogram[i] = m.holdHistogram[i].Load()
+	}
+	return s
+}
+
+// storeMax 原子地更新最大值
+func storeMax(p *atomic.Int64, v int64) {
+	for {
+		cur := p.Load()
+		if v <= cur || p.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
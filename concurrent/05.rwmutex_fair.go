@@ -0,0 +1,189 @@
+package concurrent
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+/*
+支持 context 的公平锁
+	ChannelMutex.LockTimeout、1.basic/mutex/timeout、5.channel/trylock_timeout 各自实现了一遍超时加锁
+	它们是演示 select+timer 实现 TryLock、Timeout 的示例，保持原样；实际使用时用 FairMutex 代替
+	FairMutex、FairRWMutex 实现了 sync.Locker，并提供 LockContext、RLockContext，超时、取消都通过 ctx 表达
+	超时加锁：ctx, cancel := context.WithTimeout(ctx, d); err := m.LockContext(ctx); cancel()
+公平性
+	所有等待者在同一个 FIFO 队列中，按到达的顺序获取锁，不会像 sync.Mutex 的正常模式那样被新来的 goroutine 插队
+	有等待者时新来的 reader 也要排队，所以等待的 writer 优先于之后到达的 reader，writer 不会饿死
+	队首连续的 reader 一起被唤醒，遇到 writer 为止
+代价
+	每次加锁都要获取内部的 Mutex，竞争激烈时比 sync.Mutex 慢（见 BenchmarkFairMutex），需要公平或者可取消时才使用
+*/
+
+type fairWaiter struct {
+	write bool
+	ready chan struct{} // 获取到锁时关闭
+}
+
+// FairRWMutex 公平的、支持 context 的读写锁，零值可用
+type FairRWMutex struct {
+	mu      sync.Mutex
+	writer  bool      // 写锁是否被持有
+	readers int       // 持有读锁的数量
+	queue   list.List // *fairWaiter，FIFO
+}
+
+// Lock 请求写锁
+func (rw *FairRWMutex) Lock() {
+	_ = rw.lock(context.Background(), true)
+}
+
+// TryLock 尝试获取写锁，有等待者时不插队
+func (rw *FairRWMutex) TryLock() bool {
+	return rw.tryLock(true)
+}
+
+// LockContext 请求写锁，直到获取到锁或 ctx 结束
+// 失败时返回 ctx.Err()；ctx 结束之前已经获取到锁时返回 nil
+func (rw *FairRWMutex) LockContext(ctx context.Context) error {
+	return rw.lock(ctx, true)
+}
+
+// Unlock 释放写锁
+func (rw *FairRWMutex) Unlock() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if !rw.writer {
+		panic("fair mutex: unlock of unlocked mutex")
+	}
+	rw.writer = false
+	rw.wakeup()
+}
+
+// RLock 请求读锁
+func (rw *FairRWMutex) RLock() {
+	_ = rw.lock(context.Background(), false)
+}
+
+// TryRLock 尝试获取读锁，有等待者时不插队
+func (rw *FairRWMutex) TryRLock() bool {
+	return rw.tryLock(false)
+}
+
+// RLockContext 请求读锁，直到获取到锁或 ctx 结束
+func (rw *FairRWMutex) RLockContext(ctx context.Context) error {
+	return rw.lock(ctx, false)
+}
+
+// RUnlock 释放读锁
+func (rw *FairRWMutex) RUnlock() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.readers <= 0 {
+		panic("fair mutex: RUnlock of unlocked RWMutex")
+	}
+	rw.readers--
+	if rw.readers == 0 {
+		rw.wakeup()
+	}
+}
+
+// RLocker 返回读锁的 Locker
+func (rw *FairRWMutex) RLocker() sync.Locker {
+	return (*fairRLocker)(rw)
+}
+
+type fairRLocker FairRWMutex
+
+func (r *fairRLocker) Lock()   { (*FairRWMutex)(r).RLock() }
+func (r *fairRLocker) Unlock() { (*FairRWMutex)(r).RUnlock() }
+
+func (rw *FairRWMutex) tryLock(write bool) bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.queue.Len() > 0 || !rw.available(write) {
+		return false
+	}
+	rw.grant(write)
+	return true
+}
+
+func (rw *FairRWMutex) lock(ctx context.Context, write bool) error {
+	rw.mu.Lock()
+	if rw.queue.Len() == 0 && rw.available(write) { // fast path，没有等待者
+		rw.grant(write)
+		rw.mu.Unlock()
+		return nil
+	}
+	w := &fairWaiter{write: write, ready: make(chan struct{})}
+	elem := rw.queue.PushBack(w)
+	rw.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		rw.mu.Lock()
+		defer rw.mu.Unlock()
+		select {
+		case <-w.ready: // 取消之前已经获取到了锁
+			return nil
+		default:
+		}
+		isFront := rw.queue.Front() == elem
+		rw.queue.Remove(elem)
+		if isFront { // 队首的 writer 可能挡住了后面的 reader
+			rw.wakeup()
+		}
+		return ctx.Err()
+	}
+}
+
+// available 现在能否获取锁，不考虑等待者，需要持有 rw.mu
+func (rw *FairRWMutex) available(write bool) bool {
+	if write {
+		return !rw.writer && rw.readers == 0
+	}
+	return !rw.writer
+}
+
+func (rw *FairRWMutex) grant(write bool) {
+	if write {
+		rw.writer = true
+	} else {
+		rw.readers++
+	}
+}
+
+// wakeup 按 FIFO 的顺序唤醒能获取到锁的等待者，需要持有 rw.mu
+func (rw *FairRWMutex) wakeup() {
+	for elem := rw.queue.Front(); elem != nil; elem = rw.queue.Front() {
+		w := elem.Value.(*fairWaiter)
+		if !rw.available(w.write) {
+			return
+		}
+		rw.queue.Remove(elem)
+		rw.grant(w.write)
+		close(w.ready)
+		if w.write {
+			return
+		}
+	}
+}
+
+// FairMutex 公平的、支持 context 的互斥锁，零值可用
+type FairMutex struct {
+	rw FairRWMutex
+}
+
+// Lock 请求锁
+func (m *FairMutex) Lock() { m.rw.Lock() }
+
+// TryLock 尝试获取锁，有等待者时不插队
+func (m *FairMutex) TryLock() bool { return m.rw.TryLock() }
+
+// LockContext 请求锁，直到获取到锁或 ctx 结束
+func (m *FairMutex) LockContext(ctx context.Context) error { return m.rw.LockContext(ctx) }
+
+// Unlock 释放锁
+func (m *FairMutex) Unlock() { m.rw.Unlock() }
@@ -10,6 +10,8 @@ import (
 	"reflect"
 	"syscall"
 	"time"
+
+	"golang/concurrent/clock"
 )
 
 /*
@@ -450,11 +452,23 @@ func ChannelMutexDemo() {
 }
 
 type ChannelMutex struct {
-	ch chan struct{}
+	ch    chan struct{}
+	clock clock.Clock // LockTimeout 计时使用的时钟
 }
 
-func NewMutex() *ChannelMutex { // 使用锁需要初始化
-	mu := &ChannelMutex{make(chan struct{}, 1)}
+// ChannelMutexOption NewMutex 的选项
+type ChannelMutexOption func(*ChannelMutex)
+
+// WithMutexClock LockTimeout 使用 c 计时，测试时可以传入 clock.Fake，默认为 clock.Real
+func WithMutexClock(c clock.Clock) ChannelMutexOption {
+	return func(m *ChannelMutex) { m.clock = c }
+}
+
+func NewMutex(opts ...ChannelMutexOption) *ChannelMutex { // 使用锁需要初始化
+	mu := &ChannelMutex{ch: make(chan struct{}, 1), clock: clock.Real}
+	for _, opt := range opts {
+		opt(mu)
+	}
 	mu.ch <- struct{}{}
 	return mu
 }
@@ -477,12 +491,12 @@ func (m *ChannelMutex) TryLock() bool { // 尝试获取锁
 	return false
 }
 func (m *ChannelMutex) LockTimeout(timeout time.Duration) bool { // 加入一个超时的设置
-	timer := time.NewTimer(timeout)
+	timer := m.clock.NewTimer(timeout)
 	select {
 	case <-m.ch:
 		timer.Stop()
 		return true
-	case <-timer.C:
+	case <-timer.C():
 	}
 	return false
 }
@@ -1,80 +1,167 @@
 package concurrent
 
 import (
+	"context"
 	"fmt"
 	"sync/atomic"
 	"unsafe"
 )
 
-// 原子操作 + lock-free 实现信号量的尝试
+// 原子操作 + lock-free 实现信号量
+// 资源数 left 通过原子操作增减，等待者通过 lock-free 队列 SemLKQueue 按 FIFO 排队
+// 任何可能让等待者继续执行的操作（Release、入队、取消）之后，都会调用一次 notifyWaiters，保证不会丢失唤醒
+
+const (
+	waiterWaiting  int32 = iota // 等待中
+	waiterAcquired              // 已分配资源
+	waiterCanceled              // 已取消（ctx 结束），由 notifyWaiters 惰性出队
+)
+
 type waiter struct {
-	n int64
-	//next  unsafe.Pointer
-	ready chan<- struct{}
+	n     int64
+	state atomic.Int32  // waiterWaiting、waiterAcquired、waiterCanceled，只能从 waiterWaiting 变为其它状态
+	ready chan struct{} // 分配到资源后被 close
 }
+
+// Semaphore 与 golang.org/x/sync/semaphore.Weighted 的 API 保持一致
+// 必须通过 NewAtomicSemaphore 创建，零值不可用（等待队列为 nil）
 type Semaphore struct {
 	size int64
-	left atomic.Int64
+	left atomic.Int64 // 剩余的资源数
 	//waiters list.List // api
-	waiters SemLKQueue
+	waiters *SemLKQueue // 元素为 *waiter
+}
+
+// NewAtomicSemaphore 创建一个最大资源数为 n 的信号量
+func NewAtomicSemaphore(n int64) *Semaphore {
+	s := &Semaphore{size: n, waiters: NewSemLKQueue()}
+	s.left.Store(n)
+	return s
 }
 
+// StructVal 队列中如果存放 waiter 的值，修改的只是副本，所以等待队列中存放的是 *waiter
 func StructVal() {
-	s := Semaphore{waiters: *NewSemLKQueue()}
-	w1 := waiter{n: 1}
-	w2 := waiter{n: 10}
-	s.waiters.SemEnqueue(w1)
-	s.waiters.SemEnqueue(w2)
+	type waiter struct{ n int64 }
+	q := NewSemLKQueue()
+	q.SemEnqueue(waiter{n: 1})
+	q.SemEnqueue(waiter{n: 10})
 
-	next := s.waiters.SemFront()
+	next := q.SemFront()
 	w := next.val.(waiter)
 	w.n++
-	s.waiters.Range(nil)
+	q.Range(nil)
 	fmt.Println(next.val.(waiter).n) // 1
 
 	fmt.Println()
-	//next.val.(waiter).n = 3
-	fmt.Println(next == s.waiters.SemFront()) // true
+	fmt.Println(next == q.SemFront()) // true
+
+	// 存放指针，修改对队列可见
+	p := NewSemLKQueue()
+	p.SemEnqueue(&waiter{n: 1})
+	p.SemFront().val.(*waiter).n++
+	fmt.Println(p.SemFront().val.(*waiter).n) // 2
+
+	// 只有队首还是 next 时才出队
+	fmt.Println(q.SemDequeueNode(next))      // true
+	fmt.Println(q.SemDequeueNode(next))      // false
+	fmt.Println(q.SemFront().val.(waiter).n) // 10
+}
+
+// Acquire 请求 n 个资源，直到资源可用或 ctx 结束
+// 成功返回 nil；失败返回 ctx.Err()，信号量保持不变
+// 如果 ctx 已结束，Acquire 仍可能不阻塞地成功
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	if s.tryAcquire(n) { // fast path
+		return nil
+	}
+	if n > s.size { // 不可能完成的请求，不进入队列，以免阻塞其它等待者
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	w := &waiter{n: n, ready: make(chan struct{})}
+	s.waiters.SemEnqueue(w)
+	s.notifyWaiters() // 入队前资源可能已经被释放，需要自己检查一次，避免丢失唤醒
 
-	// 修改第一个结点
-	new := next
-	w.n = 0
-	new.val = w
-	//new.val.(waiter).n = 0
-	//if s.left.CompareAndSwap(cur, cur-w.n) {
-	//	s.waiters.SemDequeueNode(next)
-	//}
-	s.waiters.SemDequeueNode(next, new)
-	s.waiters.Range(nil) // 0
+	select {
+	case <-ctx.Done():
+		if !w.state.CompareAndSwap(waiterWaiting, waiterCanceled) {
+			// 取消之前已经分配到了资源，忽略 ctx 的状态
+			return nil
+		}
+		// 可能正是自己挡住了后面的等待者
+		s.notifyWaiters()
+		return ctx.Err()
+	case <-w.ready:
+		return nil
+	}
 }
 
-func (s *Semaphore) SemAcquire() {
+// TryAcquire 不阻塞地请求 n 个资源，成功返回 true，失败返回 false 且信号量保持不变
+func (s *Semaphore) TryAcquire(n int64) bool {
+	return s.tryAcquire(n)
+}
 
+// Release 释放 n 个资源
+func (s *Semaphore) Release(n int64) {
+	if s.left.Add(n) > s.size {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
 }
-func (s *Semaphore) SemTryAcquire() {
 
+// tryAcquire 没有等待者时才直接获取，保证 FIFO
+// 检查队列和扣减资源之间可能有等待者入队，扣减之后再检查一次，有等待者就归还资源，不插到它前面
+func (s *Semaphore) tryAcquire(n int64) bool {
+	if s.waiters.SemFront() != nil || !s.tryTake(n) {
+		return false
+	}
+	if s.waiters.SemFront() != nil {
+		s.left.Add(n)
+		s.notifyWaiters() // 归还的资源可能正好够队首的等待者
+		return false
+	}
+	return true
 }
-func (s *Semaphore) SemRelease() {
 
+// tryTake 通过 CAS 从 left 中扣减 n 个资源
+func (s *Semaphore) tryTake(n int64) bool {
+	for {
+		cur := s.left.Load()
+		if cur < n {
+			return false
+		}
+		if s.left.CompareAndSwap(cur, cur-n) {
+			return true
+		}
+	}
 }
+
+// notifyWaiters 逐个检查队首的等待者，资源足够就分配给它
+// 多个 goroutine 可以同时执行：谁把 waiter 的状态改为 waiterAcquired，谁就负责 close(ready)，出队则大家互相帮助
 func (s *Semaphore) notifyWaiters() {
 	for {
 		next := s.waiters.SemFront()
 		if next == nil {
-			break
+			return // 没有等待者了
 		}
-		w := next.val.(waiter)
-		if cur := s.left.Load(); cur < w.n {
-			break
-		} else {
-			new := next
-			w.n = 0
-			new.val = w
-			// 参考 Mutex、WaitGroup、RWMutex、Pool 等的实现
-			if s.waiters.SemDequeueNode(next, new) { // 出队和获取资源都需要同步，一个条件变量不能保护两个资源
-				s.waiters.SemDequeue()
-			}
+		w := next.val.(*waiter)
+		if w.state.Load() != waiterWaiting { // 已取消或已被其它 goroutine 分配，帮忙出队
+			s.waiters.SemDequeueNode(next)
+			continue
+		}
+		if !s.tryTake(w.n) {
+			// 资源不够，不去检查后面请求资源更少的等待者，避免饥饿
+			return
 		}
+		if !w.state.CompareAndSwap(waiterWaiting, waiterAcquired) {
+			// 与取消操作竞争失败，归还资源后继续
+			s.left.Add(w.n)
+			s.waiters.SemDequeueNode(next)
+			continue
+		}
+		s.waiters.SemDequeueNode(next)
+		close(w.ready)
 	}
 }
 
@@ -146,33 +233,32 @@ func (q *SemLKQueue) SemDequeue() any {
 		}
 	}
 }
-func (q *SemLKQueue) SemDequeueNode(old, new *semNode) bool {
-	head := semLoad(&q.head)
-	nextUP := unsafe.Pointer(semLoad(&head.next))
-	if nextUP == nil {
-		return false
+
+// SemDequeueNode 只有当队首结点是 n 时才出队，返回是否由本次调用出队
+// 多个 goroutine 对同一个结点出队时，只会成功一次
+func (q *SemLKQueue) SemDequeueNode(n *semNode) bool {
+	for {
+		head := semLoad(&q.head)
+		tail := semLoad(&q.tail)
+		next := semLoad(&head.next)
+		if head != semLoad(&q.head) {
+			continue
+		}
+		if next != n || next == nil { // 队首已经不是 n 了
+			return false
+		}
+		if head == tail { // 尾指针落后了，先调整尾指针
+			semCas(&q.tail, tail, next)
+			continue
+		}
+		if semCas(&q.head, head, next) {
+			return true
+		}
 	}
-	return atomic.CompareAndSwapPointer(
-		&nextUP, unsafe.Pointer(old), unsafe.Pointer(new))
-
-	//for {
-	//	head := semLoad(&q.head)
-	//	next := semLoad(&head.next)
-	//	if head == semLoad(&q.head) {
-	//		if next != n {
-	//			return nil
-	//		} else {
-	//			v := next.val                    // 读取出队的数据
-	//			if semCas(&q.head, head, next) { // 既然要出队了，头指针移动到下一个
-	//				return v // Dequeue is done. return
-	//			}
-	//		}
-	//	}
-	//}
 }
 func (q *SemLKQueue) Range(n *semNode) {
 	if n == nil {
-		n = (*semNode)(load(&q.head).next)
+		n = semLoad(&semLoad(&q.head).next)
 	}
 	//for cur := (*node)(load(&q.tail).next); cur != nil; cur = (*node)(cur.next) {
 	for cur := n; cur != nil; cur = (*semNode)(cur.next) {
@@ -0,0 +1,390 @@
+package concurrent
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/petermattis/goid"
+)
+
+/*
+优先级感知的并发原语
+
+优先级反转
+	低优先级的任务持有锁，高优先级的任务等待这把锁，中优先级的任务抢占了低优先级任务的执行，导致高优先级任务被无限期推迟
+	Mars Pathfinder 的解决办法：打开互斥锁的优先级继承（见 restart.go）
+优先级信号量 PrioritySemaphore
+	等待者带着优先级请求资源，优先级高的先得到资源，同优先级 FIFO
+	老化（aging）：每等待 aging 时长，等待者的优先级提升 1，避免低优先级的请求饿死
+	所有等待者的老化速度相同，所以比较 prio_a + (now-enq_a)/aging 与 prio_b + (now-enq_b)/aging，等价于比较 prio - enq/aging
+	也就是说排序的 key 与当前时间无关，可以直接用堆维护
+优先级互斥锁 PriorityMutex
+	等待者按优先级获取锁，并记录持有锁的 goroutine
+	优先级继承：高优先级的等待者到来时，持有者的有效优先级被提升到等待者的优先级
+	如果持有者自己也在等待另一把 PriorityMutex，提升会沿着等待链传递下去
+*/
+
+// ==========PrioritySemaphore==========
+
+type prioWaiter struct {
+	n     int64
+	key   float64 // 老化后的排序 key，越大越优先
+	seq   uint64  // 同 key 时 FIFO
+	index int     // 在堆中的位置，-1 表示已出堆
+	ready chan struct{}
+}
+
+type prioWaiterHeap []*prioWaiter
+
+func (h prioWaiterHeap) Len() int { return len(h) }
+func (h prioWaiterHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key > h[j].key
+	}
+	return h[i].seq < h[j].seq
+}
+func (h prioWaiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *prioWaiterHeap) Push(x any) {
+	w := x.(*prioWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+func (h *prioWaiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
+
+// PrioritySemaphore 带优先级和老化的加权信号量
+type PrioritySemaphore struct {
+	size    int64
+	cur     int64
+	aging   time.Duration
+	start   time.Time
+	seq     uint64
+	mu      sync.Mutex
+	waiters prioWaiterHeap
+}
+
+// NewPrioritySemaphore 创建一个最大资源数为 n 的优先级信号量
+// 每等待 aging 时长，等待者的优先级提升 1；aging <= 0 时不老化
+func NewPrioritySemaphore(n int64, aging time.Duration) *PrioritySemaphore {
+	return &PrioritySemaphore{size: n, aging: aging, start: time.Now()}
+}
+
+// Acquire 以 priority 优先级请求 n 个资源，直到资源可用或 ctx 结束
+// 成功返回 nil；失败返回 ctx.Err()，信号量保持不变
+func (s *PrioritySemaphore) Acquire(ctx context.Context, n int64, priority int) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && len(s.waiters) == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	s.seq++
+	w := &prioWaiter{n: n, key: s.key(priority), seq: s.seq, ready: make(chan struct{})}
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		err := ctx.Err()
+		s.mu.Lock()
+		select {
+		case <-w.ready: // 取消之前已经分配到了资源
+			err = nil
+		default:
+			isFront := w.index == 0
+			heap.Remove(&s.waiters, w.index)
+			if isFront && s.size > s.cur { // 自己挡住了后面的等待者
+				s.notifyWaiters()
+			}
+		}
+		s.mu.Unlock()
+		return err
+	case <-w.ready:
+		return nil
+	}
+}
+
+// TryAcquire 不阻塞地请求 n 个资源，有等待者时不插队
+func (s *PrioritySemaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	success := s.size-s.cur >= n && len(s.waiters) == 0
+	if success {
+		s.cur += n
+	}
+	s.mu.Unlock()
+	return success
+}
+
+// Release 释放 n 个资源
+func (s *PrioritySemaphore) Release(n int64) {
+	s.mu.Lock()
+	s.cur -= n
+	if s.cur < 0 {
+		s.mu.Unlock()
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+	s.mu.Unlock()
+}
+
+// key 计算老化后的排序 key
+func (s *PrioritySemaphore) key(priority int) float64 {
+	if s.aging <= 0 {
+		return float64(priority)
+	}
+	return float64(priority) - float64(time.Since(s.start))/float64(s.aging)
+}
+
+// notifyWaiters 按优先级依次分配资源，堆顶的资源不够就停止，避免大请求饿死
+func (s *PrioritySemaphore) notifyWaiters() {
+	for len(s.waiters) > 0 {
+		w := s.waiters[0]
+		if s.size-s.cur < w.n {
+			break
+		}
+		s.cur += w.n
+		heap.Pop(&s.waiters)
+		close(w.ready)
+	}
+}
+
+// ==========PriorityMutex==========
+
+// pmMu 保护所有 PriorityMutex 的状态
+// 优先级继承需要沿着等待链修改多把锁，用一把全局锁避免锁序问题
+var (
+	pmMu     sync.Mutex
+	pmOwners = map[int64]*pmOwner{} // goroutine id -> 持有和等待的 PriorityMutex
+)
+
+type pmOwner struct {
+	held    map[*PriorityMutex]struct{}
+	blocked *pmWaiter // 正在等待的锁
+}
+
+type pmWaiter struct {
+	m     *PriorityMutex
+	gid   int64
+	base  int // 请求时指定的优先级
+	prio  int // 继承后的有效优先级
+	seq   uint64
+	index int
+	ready chan struct{}
+}
+
+type pmWaiterHeap []*pmWaiter
+
+func (h pmWaiterHeap) Len() int { return len(h) }
+func (h pmWaiterHeap) Less(i, j int) bool {
+	if h[i].prio != h[j].prio {
+		return h[i].prio > h[j].prio
+	}
+	return h[i].seq < h[j].seq
+}
+func (h pmWaiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *pmWaiterHeap) Push(x any) {
+	w := x.(*pmWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+func (h *pmWaiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
+
+// PriorityMutex 支持优先级继承的互斥锁
+// 只有持有锁的 goroutine 才能 Unlock
+type PriorityMutex struct {
+	locked  bool
+	owner   int64 // 持有锁的 goroutine id
+	base    int   // 持有者请求时指定的优先级
+	seq     uint64
+	waiters pmWaiterHeap
+}
+
+// Lock 以 priority 优先级请求锁
+func (m *PriorityMutex) Lock(priority int) {
+	_ = m.LockContext(context.Background(), priority)
+}
+
+// TryLock 尝试获取锁，有等待者时不插队
+func (m *PriorityMutex) TryLock(priority int) bool {
+	gid := goid.Get()
+	pmMu.Lock()
+	defer pmMu.Unlock()
+	if m.locked || len(m.waiters) > 0 {
+		return false
+	}
+	m.grant(gid, priority)
+	return true
+}
+
+// LockContext 以 priority 优先级请求锁，直到获取到锁或 ctx 结束
+func (m *PriorityMutex) LockContext(ctx context.Context, priority int) error {
+	gid := goid.Get()
+	pmMu.Lock()
+	if !m.locked && len(m.waiters) == 0 {
+		m.grant(gid, priority)
+		pmMu.Unlock()
+		return nil
+	}
+	if m.locked && m.owner == gid {
+		pmMu.Unlock()
+		panic(fmt.Sprintf("priority mutex: goroutine %d already holds the lock", gid))
+	}
+
+	m.seq++
+	w := &pmWaiter{m: m, gid: gid, base: priority, seq: m.seq, ready: make(chan struct{})}
+	w.prio = max(priority, pmPriority(gid)) // 自己持有的锁可能已经被提升
+	heap.Push(&m.waiters, w)
+	pmOwnerOf(gid).blocked = w
+	pmPropagate(m.owner) // 提升持有者
+	pmMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		pmMu.Lock()
+		defer pmMu.Unlock()
+		select {
+		case <-w.ready: // 取消之前已经获取到了锁
+			return nil
+		default:
+		}
+		heap.Remove(&m.waiters, w.index)
+		pmOwners[gid].blocked = nil
+		pmRelease(gid)
+		pmPropagate(m.owner) // 撤销对持有者的提升
+		return ctx.Err()
+	case <-w.ready:
+		return nil
+	}
+}
+
+// Unlock 释放锁，锁交给优先级最高的等待者
+func (m *PriorityMutex) Unlock() {
+	gid := goid.Get()
+	pmMu.Lock()
+	defer pmMu.Unlock()
+	if !m.locked {
+		panic("priority mutex: unlock of unlocked mutex")
+	}
+	if m.owner != gid {
+		panic(fmt.Sprintf("priority mutex: goroutine %d unlocks the mutex held by goroutine %d", gid, m.owner))
+	}
+	m.locked = false
+	delete(pmOwners[gid].held, m)
+	pmRelease(gid) // 持有者的提升随锁一起释放
+
+	if len(m.waiters) == 0 {
+		return
+	}
+	w := heap.Pop(&m.waiters).(*pmWaiter)
+	pmOwners[w.gid].blocked = nil
+	m.grant(w.gid, w.base)
+	close(w.ready) // 剩余的等待者会提升新的持有者
+}
+
+// HolderPriority 返回持有者继承后的有效优先级，锁未被持有时 ok 为 false
+func (m *PriorityMutex) HolderPriority() (priority int, ok bool) {
+	pmMu.Lock()
+	defer pmMu.Unlock()
+	if !m.locked {
+		return 0, false
+	}
+	return pmPriority(m.owner), true
+}
+
+// grant 把锁交给 gid，需要持有 pmMu
+func (m *PriorityMutex) grant(gid int64, priority int) {
+	m.locked = true
+	m.owner = gid
+	m.base = priority
+	pmOwnerOf(gid).held[m] = struct{}{}
+}
+
+// pmOwnerOf 获取 goroutine 的记录，需要持有 pmMu
+func pmOwnerOf(gid int64) *pmOwner {
+	o, ok := pmOwners[gid]
+	if !ok {
+		o = &pmOwner{held: map[*PriorityMutex]struct{}{}}
+		pmOwners[gid] = o
+	}
+	return o
+}
+
+// pmRelease 不再持有和等待任何锁时，删除 goroutine 的记录
+func pmRelease(gid int64) {
+	if o, ok := pmOwners[gid]; ok && len(o.held) == 0 && o.blocked == nil {
+		delete(pmOwners, gid)
+	}
+}
+
+// pmPriority goroutine 的有效优先级：所持有的锁的请求优先级和其等待者优先级的最大值
+func pmPriority(gid int64) int {
+	o, ok := pmOwners[gid]
+	if !ok {
+		return 0
+	}
+	p, first := 0, true
+	for m := range o.held {
+		mp := m.base
+		if len(m.waiters) > 0 {
+			mp = max(mp, m.waiters[0].prio)
+		}
+		if first || mp > p {
+			p, first = mp, false
+		}
+	}
+	if first && o.blocked != nil {
+		return o.blocked.base
+	}
+	return p
+}
+
+// pmPropagate gid 的有效优先级变化后，沿着等待链更新等待者的优先级
+// 等待链成环说明已经死锁了，最多走 len(pmOwners) 步
+func pmPropagate(gid int64) {
+	for i := 0; i <= len(pmOwners); i++ {
+		o, ok := pmOwners[gid]
+		if !ok || o.blocked == nil {
+			return
+		}
+		w := o.blocked
+		p := max(w.base, pmPriority(gid))
+		if p == w.prio {
+			return
+		}
+		w.prio = p
+		heap.Fix(&w.m.waiters, w.index)
+		gid = w.m.owner
+	}
+}
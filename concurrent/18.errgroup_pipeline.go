@@ -8,12 +8,11 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 
-	"golang.org/x/sync/errgroup"
+	"golang/concurrent/errgroupx"
 )
 
 // ExampleGroup_pipeline Pipeline demonstrates the use of a Group to implement a multi-stage
@@ -38,64 +37,47 @@ type result struct {
 // MD5All reads all the files in the file tree rooted at root and returns a map
 // from file path to the MD5 sum of the file's contents. If the directory walk
 // fails or any read operation fails, MD5All returns an error.
+//
+// 使用 errgroupx.Group 改写：每个文件一个返回 result 的子任务，SetLimit 限制最多 20 个同时计算，
+// Wait 按提交的顺序返回所有的结果，不再需要 paths 和 c 两个 channel 以及关闭它们的 goroutine
 func MD5All(ctx context.Context, root string) (map[string][md5.Size]byte, error) {
 	// ctx is canceled when g.Wait() returns. When this version of MD5All returns
 	// - even in case of error! - we know that all of the goroutines have finished
 	// and the memory they were using can be garbage-collected.
-	g, ctx := errgroup.WithContext(ctx)
-	paths := make(chan string) // 文件路径channel
+	g, ctx := errgroupx.WithContext[result](ctx, errgroupx.RecoverPanics()) // 某个文件计算时 panic 也只是返回 error
+	const numDigesters = 20
+	g.SetLimit(numDigesters) // 达到20个时，Go 阻塞遍历，直到有子任务完成
 
-	g.Go(func() error {
-		defer close(paths) // 遍历完关闭paths chan
-		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil { // 已经有子任务失败，停止遍历
+			return err
+		}
+		g.Go(func() (result, error) {
+			data, err := os.ReadFile(path)
 			if err != nil {
-				return err
-			}
-			if !info.Mode().IsRegular() {
-				return nil
-			}
-			select {
-			case paths <- path: // 将文件路径放入到paths
-			case <-ctx.Done():
-				return ctx.Err()
+				return result{}, err
 			}
-			return nil
+			return result{path, md5.Sum(data)}, nil // 计算path的md5值，作为子任务的结果
 		})
+		return nil
 	})
 
-	// Start a fixed number of goroutines to read and digest files.
-	c := make(chan result) // 启动20个goroutine执行计算md5的任务，计算的文件由上一阶段的文件遍历子任务生成
-	const numDigesters = 20
-	for i := 0; i < numDigesters; i++ {
-		g.Go(func() error {
-			for path := range paths { // 遍历直到paths chan被关闭
-				data, err := ioutil.ReadFile(path)
-				if err != nil {
-					return err
-				}
-				select {
-				case c <- result{path, md5.Sum(data)}: // 计算path的md5值，放入到c中
-				case <-ctx.Done():
-					return ctx.Err()
-				}
-			}
-			return nil
-		})
+	results, err := g.Wait() // 所有子任务都执行完，返回它们的结果和第一个error
+	if err != nil {
+		return nil, err
 	}
-	go func() {
-		g.Wait() // 20个goroutine以及遍历文件的goroutine都执行完
-		close(c) // 关闭收集结果的chan
-	}()
-
-	m := make(map[string][md5.Size]byte)
-	for r := range c { // 将md5结果从chan中读取到map中,直到c被关闭才退出
-		m[r.path] = r.sum
+	if walkErr != nil {
+		return nil, walkErr
 	}
-	// Check whether any of the goroutines failed. Since g is accumulating the
-	// errors, we don't need to send them (or check for them) in the individual
-	// results sent on the channel.
-	if err := g.Wait(); err != nil { // 再次调用Wait，依然可以得到group的error信息
-		return nil, err
+	m := make(map[string][md5.Size]byte, len(results))
+	for _, r := range results {
+		m[r.path] = r.sum
 	}
 	return m, nil
 }
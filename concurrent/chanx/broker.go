@@ -0,0 +1,312 @@
+package chanx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+进程内的发布订阅
+	FanOut、Broadcast 受最慢的消费者限制，也没有按主题路由
+	Broker 按主题把消息投递给订阅者，每个订阅者有自己的缓冲，缓冲满时按订阅时指定的策略处理：
+		Block      发布者等待，直到缓冲有空位、ctx 结束或者订阅被取消
+		Drop       丢弃这条消息，Dropped 记录丢弃的数量
+		Disconnect 取消这个订阅，C() 被关闭，Err 返回 ErrSlowSubscriber
+主题
+	以 . 分隔的若干段，例如 order.created.eu
+	订阅时可以使用通配符：* 匹配一段，# 只能放在最后，匹配零段或者多段
+	order.* 匹配 order.created，不匹配 order.created.eu；order.# 匹配 order、order.created.eu
+优雅退出，与 ChannelShutdownDoCleanup 一样分为两个阶段
+	closing：不再接受新的发布，等待正在进行的发布完成
+	closed：关闭所有订阅者的 channel，缓冲中的消息仍然可以读完
+	Close 的 ctx 结束时不再等待，正在阻塞的发布者返回 ErrBrokerClosed
+*/
+
+var (
+	ErrBrokerClosed   = errors.New("chanx: broker closed")
+	ErrSlowSubscriber = errors.New("chanx: subscriber disconnected for being too slow")
+	ErrInvalidTopic   = errors.New("chanx: invalid topic")
+)
+
+// Policy 订阅者的缓冲满时的处理策略
+type Policy int
+
+const (
+	Block Policy = iota
+	Drop
+	Disconnect
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case Drop:
+		return "drop"
+	case Disconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// Message 投递给订阅者的消息
+type Message[T any] struct {
+	Topic string
+	Value T
+}
+
+// Broker 发布订阅的代理，零值不可用，使用 NewBroker 创建
+type Broker[T any] struct {
+	mu       sync.RWMutex
+	subs     map[*Subscription[T]]struct{}
+	inflight sync.WaitGroup // 正在进行的发布
+
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+// NewBroker 创建一个 Broker
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{
+		subs:    make(map[*Subscription[T]]struct{}),
+		closing: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Subscribe 订阅匹配 pattern 的主题，buffer 为订阅者的缓冲大小
+func (b *Broker[T]) Subscribe(pattern string, buffer int, policy Policy) (*Subscription[T], error) {
+	segs, err := splitTopic(pattern, true)
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscription[T]{
+		b:       b,
+		pattern: segs,
+		policy:  policy,
+		ch:      make(chan Message[T], buffer),
+		done:    make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if isClosed(b.closing) {
+		return nil, ErrBrokerClosed
+	}
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Publish 把 v 发布到 topic，topic 中不能有通配符
+// Block 策略的订阅者缓冲满时会等待，ctx 结束时放弃剩下的投递，返回 ctx.Err()
+func (b *Broker[T]) Publish(ctx context.Context, topic string, v T) error {
+	segs, err := splitTopic(topic, false)
+	if err != nil {
+		return err
+	}
+	b.mu.RLock()
+	if isClosed(b.closing) {
+		b.mu.RUnlock()
+		return ErrBrokerClosed
+	}
+	b.inflight.Add(1) // 在读锁内 Add，不会与 Close 中的 Wait 竞争
+	var targets []*Subscription[T]
+	for s := range b.subs {
+		if matchTopic(s.pattern, segs) {
+			targets = append(targets, s)
+		}
+	}
+	b.mu.RUnlock()
+	defer b.inflight.Done()
+
+	// 不持有 b.mu 投递，阻塞的投递不会影响订阅和取消订阅
+	msg := Message[T]{Topic: topic, Value: v}
+	for _, s := range targets {
+		if err := s.deliver(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribers 当前订阅者的数量
+func (b *Broker[T]) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close 两阶段关闭：先拒绝新的发布并等待正在进行的发布完成，再关闭所有订阅
+// ctx 结束时不再等待，直接关闭所有订阅，返回 ctx.Err()
+func (b *Broker[T]) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if isClosed(b.closing) {
+		b.mu.Unlock()
+		<-b.closed
+		return nil
+	}
+	close(b.closing)
+	b.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*Subscription[T]]struct{})
+	b.mu.Unlock()
+	for s := range subs { // 唤醒还在阻塞的发布者
+		s.close(ErrBrokerClosed)
+	}
+	<-drained
+	close(b.closed)
+	return err
+}
+
+// Closing 开始关闭时被关闭
+func (b *Broker[T]) Closing() <-chan struct{} { return b.closing }
+
+// Closed 关闭完成时被关闭
+func (b *Broker[T]) Closed() <-chan struct{} { return b.closed }
+
+func (b *Broker[T]) remove(s *Subscription[T]) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// ==========Subscription==========
+
+// Subscription 一个订阅，从 C() 读取消息
+type Subscription[T any] struct {
+	b       *Broker[T]
+	pattern []string
+	policy  Policy
+	dropped atomic.Int64
+
+	mu     sync.RWMutex // 投递时持有读锁，关闭 ch 时持有写锁，避免向已关闭的 ch 发送
+	ch     chan Message[T]
+	done   chan struct{} // 取消订阅时关闭，唤醒阻塞的投递
+	once   sync.Once
+	err    error
+	closed bool
+}
+
+// C 接收消息的 channel，取消订阅后被关闭
+func (s *Subscription[T]) C() <-chan Message[T] { return s.ch }
+
+// Unsubscribe 取消订阅，之后 C() 会被关闭，缓冲中的消息仍然可以读取
+func (s *Subscription[T]) Unsubscribe() { s.close(nil) }
+
+// Dropped Drop 策略下丢弃的消息数量
+func (s *Subscription[T]) Dropped() int64 { return s.dropped.Load() }
+
+// Err 订阅被动结束的原因：ErrSlowSubscriber、ErrBrokerClosed；仍在订阅或者主动取消时为 nil
+func (s *Subscription[T]) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+func (s *Subscription[T]) deliver(ctx context.Context, msg Message[T]) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil
+	}
+	select {
+	case s.ch <- msg:
+		s.mu.RUnlock()
+		return nil
+	default:
+	}
+
+	switch s.policy {
+	case Drop:
+		s.dropped.Add(1)
+	case Disconnect:
+		s.mu.RUnlock()
+		s.close(ErrSlowSubscriber)
+		return nil
+	default:
+		defer s.mu.RUnlock()
+		select {
+		case s.ch <- msg:
+		case <-s.done:
+			if isClosed(s.b.closing) { // Close 不再等待
+				return ErrBrokerClosed
+			}
+			// 订阅者取消订阅不影响发布
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+	s.mu.RUnlock()
+	return nil
+}
+
+func (s *Subscription[T]) close(err error) {
+	s.once.Do(func() {
+		s.b.remove(s)
+		close(s.done) // 先唤醒阻塞的投递，它们释放读锁后才能关闭 ch
+		s.mu.Lock()
+		s.err = err
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// ==========topic==========
+
+// splitTopic 把主题按 . 分段，wildcard 为 false 时不允许通配符
+func splitTopic(topic string, wildcard bool) ([]string, error) {
+	segs := strings.Split(topic, ".")
+	for i, seg := range segs {
+		switch {
+		case seg == "":
+			return nil, ErrInvalidTopic
+		case seg == "*" || seg == "#":
+			if !wildcard || (seg == "#" && i != len(segs)-1) {
+				return nil, ErrInvalidTopic
+			}
+		case strings.ContainsAny(seg, "*#"):
+			return nil, ErrInvalidTopic
+		}
+	}
+	return segs, nil
+}
+
+// matchTopic 主题 topic 是否匹配订阅的 pattern
+func matchTopic(pattern, topic []string) bool {
+	for i, p := range pattern {
+		if p == "#" {
+			return true
+		}
+		if i >= len(topic) || (p != "*" && p != topic[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
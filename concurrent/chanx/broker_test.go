@@ -0,0 +1,159 @@
+package chanx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.paid", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order.created.eu", false},
+		{"order.*", "order", false},
+		{"*.created", "user.created", true},
+		{"order.#", "order", true},
+		{"order.#", "order.created.eu", true},
+		{"#", "anything.at.all", true},
+		{"order.*.eu", "order.paid.eu", true},
+	}
+	for _, c := range cases {
+		p, _ := splitTopic(c.pattern, true)
+		topic, _ := splitTopic(c.topic, false)
+		if got := matchTopic(p, topic); got != c.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", c.pattern, c.topic, got, c.want)
+		}
+	}
+	for _, bad := range []string{"", "a..b", "a.#.b", "a.b*"} {
+		if _, err := splitTopic(bad, true); !errors.Is(err, ErrInvalidTopic) {
+			t.Errorf("splitTopic(%q) err = %v", bad, err)
+		}
+	}
+	if _, err := splitTopic("a.*", false); !errors.Is(err, ErrInvalidTopic) {
+		t.Error("publish topic with wildcard accepted")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+	b := NewBroker[int]()
+
+	all, _ := b.Subscribe("order.#", 10, Block)
+	created, _ := b.Subscribe("order.created", 10, Block)
+	for i := 0; i < 3; i++ {
+		if err := b.Publish(ctx, "order.created", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Publish(ctx, "order.paid", 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.C()) != 4 || len(created.C()) != 3 {
+		t.Fatalf("got %d, %d messages, want 4, 3", len(all.C()), len(created.C()))
+	}
+	if m := <-created.C(); m.Topic != "order.created" || m.Value != 0 {
+		t.Fatalf("got %+v", m)
+	}
+
+	// 取消订阅后 C() 关闭，缓冲中的消息仍然可以读取
+	created.Unsubscribe()
+	if got := len(collect(created.C())); got != 2 {
+		t.Fatalf("read %d buffered messages after Unsubscribe, want 2", got)
+	}
+	if created.Err() != nil || b.Subscribers() != 1 {
+		t.Fatal("Unsubscribe")
+	}
+
+	// 两阶段关闭：拒绝新的发布和订阅，关闭所有订阅
+	if err := b.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(collect(all.C())); got != 4 {
+		t.Fatalf("read %d buffered messages after Close, want 4", got)
+	}
+	if !errors.Is(all.Err(), ErrBrokerClosed) {
+		t.Fatalf("Err = %v", all.Err())
+	}
+	if err := b.Publish(ctx, "order.created", 0); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("Publish after Close: %v", err)
+	}
+	if _, err := b.Subscribe("order.#", 1, Block); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("Subscribe after Close: %v", err)
+	}
+	<-b.Closed()
+}
+
+func TestBrokerPolicy(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+	b := NewBroker[int]()
+	defer b.Close(ctx)
+
+	drop, _ := b.Subscribe("t", 1, Drop)
+	disconnect, _ := b.Subscribe("t", 1, Disconnect)
+	for i := 0; i < 3; i++ {
+		if err := b.Publish(ctx, "t", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if drop.Dropped() != 2 || (<-drop.C()).Value != 0 {
+		t.Fatalf("Dropped = %d, want 2", drop.Dropped())
+	}
+	if got := collect(disconnect.C()); len(got) != 1 || !errors.Is(disconnect.Err(), ErrSlowSubscriber) {
+		t.Fatalf("disconnect: %v, %v", got, disconnect.Err())
+	}
+	drop.Unsubscribe()
+
+	// Block：缓冲满时等待，ctx 结束时返回
+	block, _ := b.Subscribe("t", 1, Block)
+	b.Publish(ctx, "t", 0)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := b.Publish(tctx, "t", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Publish to full subscriber: %v", err)
+	}
+	// 订阅者读取后，阻塞的发布者继续
+	published := make(chan error)
+	go func() { published <- b.Publish(ctx, "t", 2) }()
+	time.Sleep(10 * time.Millisecond)
+	<-block.C()
+	if err := <-published; err != nil || (<-block.C()).Value != 2 {
+		t.Fatal("blocked Publish not resumed")
+	}
+	// 取消订阅唤醒阻塞的发布者
+	b.Publish(ctx, "t", 3)
+	go func() { published <- b.Publish(ctx, "t", 4) }()
+	time.Sleep(10 * time.Millisecond)
+	block.Unsubscribe()
+	if err := <-published; err != nil {
+		t.Fatalf("Publish after Unsubscribe: %v", err)
+	}
+}
+
+func TestBrokerCloseTimeout(t *testing.T) {
+	checkLeak(t)
+	b := NewBroker[int]()
+	s, _ := b.Subscribe("t", 0, Block) // 没有人读取
+	published := make(chan error)
+	go func() { published <- b.Publish(context.Background(), "t", 1) }()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close = %v", err)
+	}
+	if err := <-published; !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("blocked Publish = %v", err)
+	}
+	if _, ok := <-s.C(); ok {
+		t.Fatal("subscription not closed")
+	}
+}
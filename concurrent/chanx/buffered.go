@@ -0,0 +1,205 @@
+package chanx
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Infinity InfiniteChan 的 Cap
+const Infinity = -1
+
+// 缓冲 channel 的共同行为，与原生 channel 一致：
+//
+//	In 用于发送，Out 用于接收，元素按 FIFO 的顺序输出
+//	Close 关闭输入，之后再发送会 panic，重复 Close 也会 panic
+//	Close 之前缓冲的元素仍然可以从 Out 读取，读完之后 Out 被关闭
+//	内部的 goroutine 在 Out 被关闭后退出，所以 Close 之后需要读完 Out
+//	Len 是缓冲中还没有被读取的元素数量
+
+// ==========InfiniteChan==========
+
+// InfiniteChan 无界 channel，发送永远不会阻塞
+type InfiniteChan[T any] struct {
+	input  chan T
+	output chan T
+	length atomic.Int64
+}
+
+// NewInfiniteChan 创建一个无界 channel
+func NewInfiniteChan[T any]() *InfiniteChan[T] {
+	c := &InfiniteChan[T]{input: make(chan T), output: make(chan T)}
+	go c.run()
+	return c
+}
+
+func (c *InfiniteChan[T]) In() chan<- T  { return c.input }
+func (c *InfiniteChan[T]) Out() <-chan T { return c.output }
+func (c *InfiniteChan[T]) Len() int      { return int(c.length.Load()) }
+func (c *InfiniteChan[T]) Cap() int      { return Infinity }
+func (c *InfiniteChan[T]) Close()        { close(c.input) }
+
+func (c *InfiniteChan[T]) run() {
+	defer close(c.output)
+	var (
+		buf   queue[T]
+		input = c.input
+	)
+	for input != nil || buf.Len() > 0 {
+		var (
+			output chan T // 缓冲为空时为 nil，不参与 select
+			next   T
+		)
+		if buf.Len() > 0 {
+			output, next = c.output, buf.Peek()
+		}
+		select {
+		case v, ok := <-input:
+			if !ok {
+				input = nil
+				continue
+			}
+			buf.Add(v)
+		case output <- next:
+			buf.Remove()
+		}
+		c.length.Store(int64(buf.Len()))
+	}
+}
+
+// ==========RingChan==========
+
+// RingChan 环形缓冲的 channel，发送永远不会阻塞，缓冲满时丢弃最旧的元素
+type RingChan[T any] struct {
+	input   chan T
+	output  chan T
+	size    int
+	length  atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewRingChan 创建一个缓冲大小为 size 的 RingChan，size 必须大于 0
+func NewRingChan[T any](size int) *RingChan[T] {
+	if size <= 0 {
+		panic("chanx: RingChan size must be positive")
+	}
+	c := &RingChan[T]{input: make(chan T), output: make(chan T), size: size}
+	go c.run()
+	return c
+}
+
+func (c *RingChan[T]) In() chan<- T  { return c.input }
+func (c *RingChan[T]) Out() <-chan T { return c.output }
+func (c *RingChan[T]) Len() int      { return int(c.length.Load()) }
+func (c *RingChan[T]) Cap() int      { return c.size }
+func (c *RingChan[T]) Close()        { close(c.input) }
+
+// Dropped 被覆盖丢弃的元素数量
+func (c *RingChan[T]) Dropped() int64 { return c.dropped.Load() }
+
+func (c *RingChan[T]) run() {
+	defer close(c.output)
+	var (
+		buf   queue[T]
+		input = c.input
+	)
+	for input != nil || buf.Len() > 0 {
+		var (
+			output chan T
+			next   T
+		)
+		if buf.Len() > 0 {
+			output, next = c.output, buf.Peek()
+		}
+		select {
+		case v, ok := <-input:
+			if !ok {
+				input = nil
+				continue
+			}
+			if buf.Len() == c.size { // 覆盖最旧的元素
+				buf.Remove()
+				c.dropped.Add(1)
+			}
+			buf.Add(v)
+		case output <- next:
+			buf.Remove()
+		}
+		c.length.Store(int64(buf.Len()))
+	}
+}
+
+// ==========ResizableChan==========
+
+// ResizableChan 可以在运行时调整缓冲大小的 channel，缓冲满时发送会阻塞
+type ResizableChan[T any] struct {
+	input  chan T
+	output chan T
+	resize chan int
+	mu     sync.Mutex // 保证 size 与发送给 run 的顺序一致
+	size   atomic.Int64
+	length atomic.Int64
+}
+
+// NewResizableChan 创建一个缓冲大小为 size 的 ResizableChan，size 必须大于 0
+func NewResizableChan[T any](size int) *ResizableChan[T] {
+	if size <= 0 {
+		panic("chanx: ResizableChan size must be positive")
+	}
+	c := &ResizableChan[T]{input: make(chan T), output: make(chan T), resize: make(chan int)}
+	c.size.Store(int64(size))
+	go c.run(size)
+	return c
+}
+
+func (c *ResizableChan[T]) In() chan<- T  { return c.input }
+func (c *ResizableChan[T]) Out() <-chan T { return c.output }
+func (c *ResizableChan[T]) Len() int      { return int(c.length.Load()) }
+func (c *ResizableChan[T]) Cap() int      { return int(c.size.Load()) }
+func (c *ResizableChan[T]) Close()        { close(c.input) }
+
+// Resize 调整缓冲大小，size 必须大于 0
+// 缩小时已经缓冲的元素不会丢弃，读取到低于新的大小之后才能继续发送
+// Out 关闭之后调用 Resize 会 panic
+func (c *ResizableChan[T]) Resize(size int) {
+	if size <= 0 {
+		panic("chanx: ResizableChan size must be positive")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resize <- size
+	c.size.Store(int64(size))
+}
+
+func (c *ResizableChan[T]) run(size int) {
+	defer close(c.resize)
+	defer close(c.output)
+	var (
+		buf   queue[T]
+		input = c.input
+	)
+	for input != nil || buf.Len() > 0 {
+		var (
+			in     chan T // 缓冲满时为 nil，阻塞发送者
+			output chan T
+			next   T
+		)
+		if buf.Len() < size {
+			in = input
+		}
+		if buf.Len() > 0 {
+			output, next = c.output, buf.Peek()
+		}
+		select {
+		case v, ok := <-in:
+			if !ok {
+				input = nil
+				continue
+			}
+			buf.Add(v)
+		case output <- next:
+			buf.Remove()
+		case size = <-c.resize:
+		}
+		c.length.Store(int64(buf.Len()))
+	}
+}
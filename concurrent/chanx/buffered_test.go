@@ -0,0 +1,134 @@
+package chanx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	var q queue[int]
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 90; i++ {
+		if v := q.Remove(); v != i {
+			t.Fatalf("got %d, want %d", v, i)
+		}
+	}
+	for i := 100; i < 150; i++ { // 收缩之后继续环绕写入
+		q.Add(i)
+	}
+	for i := 90; i < 150; i++ {
+		if v := q.Remove(); v != i {
+			t.Fatalf("got %d, want %d", v, i)
+		}
+	}
+	if q.Len() != 0 {
+		t.Fatalf("got len %d, want 0", q.Len())
+	}
+}
+
+func TestInfiniteChan(t *testing.T) {
+	checkLeak(t)
+	c := NewInfiniteChan[int]()
+	for i := 0; i < 1000; i++ { // 没有消费者也不会阻塞
+		c.In() <- i
+	}
+	c.Close()
+	waitLen(t, c.Len, 1000)
+	if c.Cap() != Infinity {
+		t.Fatalf("got cap %d", c.Cap())
+	}
+	got := collect(c.Out()) // Close 之前的元素仍然可以读取
+	if len(got) != 1000 || got[0] != 0 || got[999] != 999 {
+		t.Fatalf("got %d elements", len(got))
+	}
+}
+
+func TestRingChan(t *testing.T) {
+	checkLeak(t)
+	c := NewRingChan[int](3)
+	for i := 0; i < 10; i++ {
+		c.In() <- i
+	}
+	waitLen(t, c.Len, 3)
+	c.Close()
+	if got := collect(c.Out()); !reflect.DeepEqual(got, []int{7, 8, 9}) {
+		t.Fatalf("got %v, want the newest 3", got)
+	}
+	if c.Dropped() != 7 {
+		t.Fatalf("got %d dropped, want 7", c.Dropped())
+	}
+}
+
+func TestResizableChan(t *testing.T) {
+	checkLeak(t)
+	c := NewResizableChan[int](2)
+	c.In() <- 0
+	c.In() <- 1
+	select {
+	case c.In() <- 2: // 缓冲满了会阻塞
+		t.Fatal("send to full ResizableChan did not block")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Resize(3)
+	if c.Cap() != 3 {
+		t.Fatalf("got cap %d, want 3", c.Cap())
+	}
+	c.In() <- 2
+	c.Resize(1) // 缩小时不丢弃已缓冲的元素
+	waitLen(t, c.Len, 3)
+	if v := <-c.Out(); v != 0 {
+		t.Fatalf("got %d, want 0", v)
+	}
+	c.Close()
+	if got := collect(c.Out()); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestCloseSemantics(t *testing.T) {
+	checkLeak(t)
+	c := NewInfiniteChan[int]()
+	c.Close()
+	if _, ok := <-c.Out(); ok {
+		t.Fatal("Out not closed")
+	}
+	mustPanic(t, func() { c.In() <- 1 }) // 与原生 channel 一致
+	mustPanic(t, c.Close)
+}
+
+func mustPanic(t *testing.T, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	f()
+}
+
+// waitLen 等待内部 goroutine 处理完发送的元素
+func waitLen(t *testing.T, length func() int, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for length() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got len %d, want %d", length(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func BenchmarkInfiniteChan(b *testing.B) {
+	c := NewInfiniteChan[int]()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			c.In() <- i
+		}
+		c.Close()
+	}()
+	for range c.Out() {
+	}
+}
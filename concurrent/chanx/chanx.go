@@ -0,0 +1,197 @@
+// Package chanx 泛型的 channel 组合器，14.channel_02.go 中 TakeN、FanOut、FanIn、OrDone、MapChanReduce 的类型安全版本
+//
+// 约定
+//
+//	所有组合器都接收 context.Context，ctx 结束或者输入 channel 关闭后，内部 goroutine 一定会退出，并关闭输出 channel
+//	组合器只负责自己的 goroutine：提前退出（比如 Take 取够了）后，不会再读取输入，上游的生产者需要自己监听同一个 ctx
+//	输出 channel 都是无缓冲的，消费者读取的速度决定了整个流水线的速度
+package chanx
+
+import (
+	"context"
+	"time"
+)
+
+// send 向 out 发送 v，ctx 结束时返回 false
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recv 从 in 读取，in 关闭或者 ctx 结束时 ok 为 false
+func recv[T any](ctx context.Context, in <-chan T) (v T, ok bool) {
+	select {
+	case v, ok = <-in:
+		return v, ok
+	case <-ctx.Done():
+		return v, false
+	}
+}
+
+// Take 只输出前 n 个元素
+func Take[T any](ctx context.Context, in <-chan T, n int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			v, ok := recv(ctx, in)
+			if !ok || !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Skip 跳过前 n 个元素
+func Skip[T any](ctx context.Context, in <-chan T, n int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := 0; ; i++ {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			if i >= n && !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Map 对每个元素执行 fn
+func Map[T, U any](ctx context.Context, in <-chan T, fn func(T) U) <-chan U {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok || !send(ctx, out, fn(v)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Filter 只输出满足 pred 的元素
+func Filter[T any](ctx context.Context, in <-chan T, pred func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			if pred(v) && !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Batch 把元素攒成最多 size 个一批输出
+// maxWait > 0 时，一批的第一个元素到达后最多等待 maxWait，不满 size 也会输出；输入关闭时输出剩余的元素
+func Batch[T any](ctx context.Context, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	if size <= 0 {
+		size = 1
+	}
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		var (
+			batch   []T
+			timer   *time.Timer
+			timeout <-chan time.Time // 当前这批的超时，没有元素时为 nil
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		flush := func() bool {
+			if timeout != nil {
+				stopTimer(timer)
+				timeout = nil
+			}
+			b := batch
+			batch = nil
+			return send(ctx, out, b)
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if len(batch) > 0 {
+						flush()
+					}
+					return
+				}
+				batch = append(batch, v)
+				if len(batch) >= size {
+					if !flush() {
+						return
+					}
+				} else if len(batch) == 1 && maxWait > 0 {
+					if timer == nil {
+						timer = time.NewTimer(maxWait)
+					} else {
+						timer.Reset(maxWait)
+					}
+					timeout = timer.C
+				}
+			case <-timeout:
+				timeout = nil
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// stopTimer 停止 timer 并清空 timer.C，之后可以安全地 Reset
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
+
+// Pair Zip 的输出
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip 依次从 a、b 各取一个元素组成一对，任意一个关闭时结束
+func Zip[A, B any](ctx context.Context, a <-chan A, b <-chan B) <-chan Pair[A, B] {
+	out := make(chan Pair[A, B])
+	go func() {
+		defer close(out)
+		for {
+			va, ok := recv(ctx, a)
+			if !ok {
+				return
+			}
+			vb, ok := recv(ctx, b)
+			if !ok || !send(ctx, out, Pair[A, B]{va, vb}) {
+				return
+			}
+		}
+	}()
+	return out
+}
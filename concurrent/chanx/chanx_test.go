@@ -0,0 +1,227 @@
+package chanx
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// checkLeak 测试结束时检查 goroutine 是否都已退出
+func checkLeak(t *testing.T) {
+	n := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > n {
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				t.Fatalf("goroutine leak: %d > %d\n%s", runtime.NumGoroutine(), n, buf[:runtime.Stack(buf, true)])
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+// generate 生成 0..n-1，ctx 结束时退出
+func generate(ctx context.Context, n int) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			if !send(ctx, out, i) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func collect[T any](in <-chan T) []T {
+	var s []T
+	for v := range in {
+		s = append(s, v)
+	}
+	return s
+}
+
+func TestTakeSkipMapFilter(t *testing.T) {
+	checkLeak(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := collect(Take(ctx, generate(ctx, 100), 3))
+	if !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Fatalf("Take got %v", got)
+	}
+	got = collect(Skip(ctx, generate(ctx, 5), 3))
+	if !reflect.DeepEqual(got, []int{3, 4}) {
+		t.Fatalf("Skip got %v", got)
+	}
+	strs := collect(Map(ctx, generate(ctx, 3), func(v int) string { return string(rune('a' + v)) }))
+	if !reflect.DeepEqual(strs, []string{"a", "b", "c"}) {
+		t.Fatalf("Map got %v", strs)
+	}
+	got = collect(Filter(ctx, generate(ctx, 6), func(v int) bool { return v%2 == 0 }))
+	if !reflect.DeepEqual(got, []int{0, 2, 4}) {
+		t.Fatalf("Filter got %v", got)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+	got := collect(Batch(ctx, generate(ctx, 7), 3, 0))
+	if !reflect.DeepEqual(got, [][]int{{0, 1, 2}, {3, 4, 5}, {6}}) {
+		t.Fatalf("Batch got %v", got)
+	}
+
+	in := make(chan int)
+	out := Batch(ctx, in, 10, 10*time.Millisecond)
+	in <- 1
+	in <- 2
+	if b := <-out; !reflect.DeepEqual(b, []int{1, 2}) { // 超时输出不满的一批
+		t.Fatalf("Batch got %v", b)
+	}
+	close(in)
+	if _, ok := <-out; ok {
+		t.Fatal("Batch output not closed")
+	}
+}
+
+func TestMergeTeeBroadcastZip(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+
+	got := collect(Merge(ctx, generate(ctx, 3), generate(ctx, 3), generate(ctx, 3)))
+	sort.Ints(got)
+	if !reflect.DeepEqual(got, []int{0, 0, 0, 1, 1, 1, 2, 2, 2}) {
+		t.Fatalf("Merge got %v", got)
+	}
+
+	a, b := Tee(ctx, generate(ctx, 3))
+	var ga, gb []int
+	for a != nil || b != nil {
+		select {
+		case v, ok := <-a:
+			if !ok {
+				a = nil
+				continue
+			}
+			ga = append(ga, v)
+		case v, ok := <-b:
+			if !ok {
+				b = nil
+				continue
+			}
+			gb = append(gb, v)
+		}
+	}
+	if !reflect.DeepEqual(ga, []int{0, 1, 2}) || !reflect.DeepEqual(gb, ga) {
+		t.Fatalf("Tee got %v %v", ga, gb)
+	}
+
+	outs := Broadcast(ctx, generate(ctx, 3), 3)
+	results := make(chan []int, len(outs))
+	for _, out := range outs {
+		go func(out <-chan int) { results <- collect(out) }(out)
+	}
+	for range outs {
+		if got := <-results; !reflect.DeepEqual(got, []int{0, 1, 2}) {
+			t.Fatalf("Broadcast got %v", got)
+		}
+	}
+	mustPanic(t, func() { Broadcast[int](ctx, nil, -1) })
+
+	// 按相反的顺序读取各个输出
+	a, b = Tee(ctx, generate(ctx, 3))
+	for i := 0; i < 3; i++ {
+		vb, va := <-b, <-a
+		if va != i || vb != i {
+			t.Fatalf("Tee reversed got %d %d, want %d", va, vb, i)
+		}
+	}
+	if _, ok := <-b; ok {
+		t.Fatal("Tee output not closed")
+	}
+	<-a
+	outs = Broadcast(ctx, generate(ctx, 2), 3)
+	for i := 0; i < 2; i++ {
+		for j := len(outs) - 1; j >= 0; j-- {
+			if v := <-outs[j]; v != i {
+				t.Fatalf("Broadcast reversed got %d, want %d", v, i)
+			}
+		}
+	}
+
+	pairs := collect(Zip(ctx, generate(ctx, 3), Map(ctx, generate(ctx, 2), func(v int) string { return string(rune('a' + v)) })))
+	if !reflect.DeepEqual(pairs, []Pair[int, string]{{0, "a"}, {1, "b"}}) {
+		t.Fatalf("Zip got %v", pairs)
+	}
+}
+
+// TestCancel 消费者不再读取时取消 ctx，所有 goroutine 都要退出
+func TestCancel(t *testing.T) {
+	checkLeak(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	src := generate(ctx, 1000)
+	outs := []<-chan int{
+		Take(ctx, src, 10),
+		Skip(ctx, src, 1),
+		Map(ctx, src, func(v int) int { return v }),
+		Filter(ctx, src, func(int) bool { return true }),
+		Merge(ctx, src, src),
+		Debounce(ctx, src, time.Millisecond),
+		Throttle(ctx, src, time.Millisecond),
+	}
+	a, b := Tee(ctx, src)
+	outs = append(outs, a, b)
+	outs = append(outs, Broadcast(ctx, src, 3)...)
+	batch := Batch(ctx, src, 2, time.Millisecond)
+	zip := Zip(ctx, src, src)
+	for _, out := range outs { // 每个输出只读一个元素
+		<-out
+	}
+	<-batch
+	<-zip
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestDebounce(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+	in := make(chan int)
+	out := Debounce(ctx, in, 20*time.Millisecond)
+	for i := 0; i < 5; i++ { // 一阵连续的输入只输出最后一个
+		in <- i
+		time.Sleep(time.Millisecond)
+	}
+	if v := <-out; v != 4 {
+		t.Fatalf("Debounce got %d, want 4", v)
+	}
+	in <- 5
+	close(in) // 关闭时输出剩余的元素
+	if got := collect(out); !reflect.DeepEqual(got, []int{5}) {
+		t.Fatalf("Debounce got %v", got)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+	in := make(chan int)
+	out := Throttle(ctx, in, 50*time.Millisecond)
+	go func() {
+		defer close(in)
+		for i := 0; i < 5; i++ { // 窗口内只有第一个元素输出
+			in <- i
+		}
+		time.Sleep(60 * time.Millisecond)
+		in <- 5
+	}()
+	if got := collect(out); !reflect.DeepEqual(got, []int{0, 5}) {
+		t.Fatalf("Throttle got %v", got)
+	}
+}
@@ -0,0 +1,86 @@
+package chanx
+
+import (
+	"context"
+	"sync"
+)
+
+// Merge 扇入，把多个 channel 的元素合并到一个 channel，所有输入都关闭后关闭输出
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		go func(in <-chan T) {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Tee 把每个元素复制到两个输出，两个输出都读取之后才处理下一个元素
+func Tee[T any](ctx context.Context, in <-chan T) (<-chan T, <-chan T) {
+	outs := Broadcast(ctx, in, 2)
+	return outs[0], outs[1]
+}
+
+// Broadcast 扇出，把每个元素发送给所有 n 个输出，所有输出都读取之后才处理下一个元素
+// 与 FanOut 的同步模式一致，受最慢的消费者限制；不会像 FanOut 的异步模式那样为每个元素启动 goroutine，ctx 结束后一定退出
+// 每个输出由一个 goroutine 发送，消费者可以按任意顺序读取各个输出
+// n 为负数时 panic
+func Broadcast[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n < 0 {
+		panic("chanx: Broadcast with negative n")
+	}
+	feeds := make([]chan T, n)
+	acks := make(chan struct{})
+	ros := make([]<-chan T, n)
+	for i := range feeds {
+		feeds[i] = make(chan T)
+		out := make(chan T)
+		ros[i] = out
+		go func(feed <-chan T) {
+			defer close(out)
+			for v := range feed {
+				if !send(ctx, out, v) || !send(ctx, acks, struct{}{}) {
+					return
+				}
+			}
+		}(feeds[i])
+	}
+	go func() {
+		defer func() {
+			for _, feed := range feeds {
+				close(feed)
+			}
+		}()
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			// 上一个元素已经发送完，所有 goroutine 都在等待 feed
+			for _, feed := range feeds {
+				if !send(ctx, feed, v) {
+					return
+				}
+			}
+			for range feeds {
+				if _, ok := recv(ctx, acks); !ok {
+					return
+				}
+			}
+		}
+	}()
+	return ros
+}
@@ -0,0 +1,56 @@
+package chanx
+
+// queue 基于环形缓冲区的 FIFO 队列，容量按 2 的幂增长和收缩，非并发安全
+type queue[T any] struct {
+	buf               []T
+	head, tail, count int
+}
+
+const minQueueLen = 16
+
+func (q *queue[T]) Len() int { return q.count }
+
+// Add 加入队尾
+func (q *queue[T]) Add(v T) {
+	if q.count == len(q.buf) {
+		q.resize(max(minQueueLen, q.count*2))
+	}
+	q.buf[q.tail] = v
+	q.tail = (q.tail + 1) & (len(q.buf) - 1)
+	q.count++
+}
+
+// Peek 队首元素，队列为空时 panic
+func (q *queue[T]) Peek() T {
+	if q.count == 0 {
+		panic("chanx: Peek() called on empty queue")
+	}
+	return q.buf[q.head]
+}
+
+// Remove 删除并返回队首元素，队列为空时 panic
+func (q *queue[T]) Remove() T {
+	if q.count == 0 {
+		panic("chanx: Remove() called on empty queue")
+	}
+	v := q.buf[q.head]
+	var zero T
+	q.buf[q.head] = zero // 不再引用已出队的元素
+	q.head = (q.head + 1) & (len(q.buf) - 1)
+	q.count--
+	if len(q.buf) > minQueueLen && q.count*4 == len(q.buf) { // 只用了 1/4 时收缩
+		q.resize(len(q.buf) / 2)
+	}
+	return v
+}
+
+func (q *queue[T]) resize(n int) {
+	buf := make([]T, n)
+	if q.tail > q.head {
+		copy(buf, q.buf[q.head:q.tail])
+	} else if q.count > 0 {
+		m := copy(buf, q.buf[q.head:])
+		copy(buf[m:], q.buf[:q.tail])
+	}
+	q.head, q.tail, q.buf = 0, q.count, buf
+}
@@ -0,0 +1,286 @@
+package chanx
+
+import (
+	"context"
+	"sync"
+)
+
+/*
+不使用 reflect 的动态 select
+	FanInReflect 每次 reflect.Select 都要构造 []reflect.SelectCase，channel 很多时又慢又分配内存
+	FanInRec 递归合并，每两个 channel 一个 goroutine，而且构造之后不能再增删 channel
+SelectAny 把 channel 分组，每组 groupSize 个，由一个 goroutine 用普通的 select 语句等待
+	空位的 channel 为 nil，nil channel 的 case 永远不会被选中
+	增删 channel 时通过 ctrl 发送给组的 goroutine，不需要重建 select
+	组的 goroutine 取出一个值后，一直阻塞到它被 Recv 读走，所以不会丢失数据
+	channel 关闭时 Recv 返回一次 ok 为 false，之后自动从集合中删除，与 FanInReflect 一致
+*/
+
+// groupSize 每个 goroutine 等待的 channel 数量，与 selectGroup.run 中的 case 数量一致
+const groupSize = 8
+
+type selected[T any] struct {
+	id int
+	v  T
+	ok bool
+}
+
+// groupOp 把组中 slot 位置的 channel 替换为 ch，ch 为 nil 表示删除
+type groupOp[T any] struct {
+	slot int
+	id   int
+	ch   <-chan T
+}
+
+type selectGroup[T any] struct {
+	ctrl chan groupOp[T]
+	used int // 已占用的 slot 数量，由 SelectAny.mu 保护
+	free [groupSize]bool
+}
+
+type selectEntry[T any] struct {
+	g    *selectGroup[T]
+	slot int
+}
+
+// SelectAny 可以动态增删的 channel 集合，Recv 从其中任意一个准备好的 channel 接收
+type SelectAny[T any] struct {
+	mu      sync.Mutex
+	nextID  int
+	entries map[int]selectEntry[T]
+	groups  []*selectGroup[T]
+	closed  bool
+
+	out  chan selected[T]
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSelectAny 创建一个 SelectAny，并加入 chs，不再使用时需要 Close
+func NewSelectAny[T any](chs ...<-chan T) *SelectAny[T] {
+	s := &SelectAny[T]{
+		entries: make(map[int]selectEntry[T]),
+		out:     make(chan selected[T]),
+		done:    make(chan struct{}),
+	}
+	for _, ch := range chs {
+		s.Add(ch)
+	}
+	return s
+}
+
+// Add 加入 ch，返回它的 id，Recv 用 id 标识数据来自哪个 channel
+// SelectAny 已经 Close 时返回 -1
+func (s *SelectAny[T]) Add(ch <-chan T) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return -1
+	}
+	g, slot := s.freeSlot()
+	id := s.nextID
+	s.nextID++
+	s.entries[id] = selectEntry[T]{g: g, slot: slot}
+	g.ctrl <- groupOp[T]{slot: slot, id: id, ch: ch}
+	return id
+}
+
+// Remove 删除 id 对应的 channel，id 不存在时返回 false
+// 删除之前已经取出的那一个值仍然会被 Recv 返回
+func (s *SelectAny[T]) Remove(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok || s.closed {
+		return false
+	}
+	e.g.ctrl <- groupOp[T]{slot: e.slot}
+	s.release(id, e)
+	return true
+}
+
+// Len 集合中 channel 的数量
+func (s *SelectAny[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Recv 从任意一个准备好的 channel 接收，返回 channel 的 id、值，以及 ok（channel 已关闭时为 false）
+// 与 reflect.Select 一致；ctx 结束或者 SelectAny 已经 Close 时 id 为 -1
+func (s *SelectAny[T]) Recv(ctx context.Context) (id int, v T, ok bool) {
+	for {
+		select {
+		case r := <-s.out:
+			if r.ok {
+				return r.id, r.v, true
+			}
+			s.mu.Lock()
+			e, exists := s.entries[r.id]
+			if exists { // 关闭的 channel 已经不再被等待，释放它的 slot
+				s.release(r.id, e)
+			}
+			s.mu.Unlock()
+			if exists { // 已经被 Remove 的 channel 不再报告关闭
+				return r.id, r.v, false
+			}
+		case <-ctx.Done():
+			return -1, v, false
+		case <-s.done:
+			return -1, v, false
+		}
+	}
+}
+
+// Close 停止所有 goroutine，之后 Recv 立即返回，不会关闭集合中的 channel
+func (s *SelectAny[T]) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// freeSlot 找一个空位，所有组都满了就新建一个组，需要持有 s.mu
+func (s *SelectAny[T]) freeSlot() (*selectGroup[T], int) {
+	for _, g := range s.groups {
+		if g.used == groupSize {
+			continue
+		}
+		for i := range g.free {
+			if g.free[i] {
+				g.free[i] = false
+				g.used++
+				return g, i
+			}
+		}
+	}
+	g := &selectGroup[T]{ctrl: make(chan groupOp[T])}
+	for i := 1; i < groupSize; i++ {
+		g.free[i] = true
+	}
+	g.used = 1
+	s.groups = append(s.groups, g)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		g.run(s.out, s.done)
+	}()
+	return g, 0
+}
+
+// release 释放 id 的 slot，需要持有 s.mu
+func (s *SelectAny[T]) release(id int, e selectEntry[T]) {
+	delete(s.entries, id)
+	e.g.free[e.slot] = true
+	e.g.used--
+}
+
+// run 等待组中的 channel，取出的值发送到 out
+func (g *selectGroup[T]) run(out chan<- selected[T], done <-chan struct{}) {
+	var (
+		chs [groupSize]<-chan T
+		ids [groupSize]int
+	)
+	apply := func(op groupOp[T]) {
+		chs[op.slot], ids[op.slot] = op.ch, op.id
+	}
+	for {
+		var (
+			i  int
+			v  T
+			ok bool
+		)
+		select {
+		case op := <-g.ctrl:
+			apply(op)
+			continue
+		case <-done:
+			return
+		case v, ok = <-chs[0]:
+			i = 0
+		case v, ok = <-chs[1]:
+			i = 1
+		case v, ok = <-chs[2]:
+			i = 2
+		case v, ok = <-chs[3]:
+			i = 3
+		case v, ok = <-chs[4]:
+			i = 4
+		case v, ok = <-chs[5]:
+			i = 5
+		case v, ok = <-chs[6]:
+			i = 6
+		case v, ok = <-chs[7]:
+			i = 7
+		}
+		r := selected[T]{id: ids[i], v: v, ok: ok}
+		if !ok { // 已关闭，不再等待
+			chs[i] = nil
+		}
+		for sent := false; !sent; { // 发送的同时继续处理增删，Add、Remove 不会被慢的 Recv 阻塞
+			select {
+			case out <- r:
+				sent = true
+			case op := <-g.ctrl:
+				apply(op)
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// ==========Merger==========
+
+// Merger 可以动态增删输入的 Merge
+// 与 Merge 不同，所有输入都关闭后 Out 不会关闭（之后还可以 Add），只有 ctx 结束或者 Close 之后才关闭
+type Merger[T any] struct {
+	sel *SelectAny[T]
+	out chan T
+}
+
+// NewMerger 创建一个 Merger，并加入 ins
+func NewMerger[T any](ctx context.Context, ins ...<-chan T) *Merger[T] {
+	m := &Merger[T]{sel: NewSelectAny(ins...), out: make(chan T)}
+	go func() {
+		defer close(m.out)
+		defer m.sel.Close()
+		for {
+			id, v, ok := m.sel.Recv(ctx)
+			if id < 0 {
+				return
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case m.out <- v:
+			case <-ctx.Done():
+				return
+			case <-m.sel.done:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// Add 加入一个输入，返回它的 id
+func (m *Merger[T]) Add(in <-chan T) int { return m.sel.Add(in) }
+
+// Remove 删除 id 对应的输入
+func (m *Merger[T]) Remove(id int) bool { return m.sel.Remove(id) }
+
+// Len 输入的数量，已关闭的输入会被自动删除
+func (m *Merger[T]) Len() int { return m.sel.Len() }
+
+// Out 合并后的输出
+func (m *Merger[T]) Out() <-chan T { return m.out }
+
+// Close 停止合并，之后 Out 会被关闭
+func (m *Merger[T]) Close() { m.sel.Close() }
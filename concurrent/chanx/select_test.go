@@ -0,0 +1,115 @@
+package chanx
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSelectAny(t *testing.T) {
+	checkLeak(t)
+	ctx := context.Background()
+
+	// 超过一组的 channel，每个值都能收到，关闭的 channel 报告一次后自动删除
+	const n = 3*groupSize + 1
+	chs := make([]chan int, n)
+	s := NewSelectAny[int]()
+	defer s.Close()
+	ids := map[int]int{}
+	for i := range chs {
+		chs[i] = make(chan int, 1)
+		ids[s.Add(chs[i])] = i
+	}
+	for i := range chs {
+		chs[i] <- i
+	}
+	var got []int
+	for range chs {
+		id, v, ok := s.Recv(ctx)
+		if !ok || ids[id] != v {
+			t.Fatalf("Recv = %d, %d, %v", id, v, ok)
+		}
+		got = append(got, v)
+	}
+	sort.Ints(got)
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("got %v", got)
+		}
+	}
+
+	close(chs[5])
+	if id, _, ok := s.Recv(ctx); ok || ids[id] != 5 {
+		t.Fatalf("closed channel: id %d, ok %v", id, ok)
+	}
+	if s.Len() != n-1 {
+		t.Fatalf("Len = %d, want %d", s.Len(), n-1)
+	}
+
+	// 删除之后不再接收，空出来的 slot 被新的 channel 复用
+	var removed int
+	for id, i := range ids {
+		if i == 7 {
+			removed = id
+		}
+	}
+	if !s.Remove(removed) || s.Remove(removed) {
+		t.Fatal("Remove")
+	}
+	chs[7] <- 7
+	extra := make(chan int, 1)
+	extraID := s.Add(extra)
+	extra <- 100
+	if id, v, ok := s.Recv(ctx); id != extraID || v != 100 || !ok {
+		t.Fatalf("Recv = %d, %d, %v, want %d, 100, true", id, v, ok, extraID)
+	}
+	if len(chs[7]) != 1 {
+		t.Fatal("removed channel was read")
+	}
+
+	// ctx 结束、Close 之后返回 -1
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if id, _, _ := s.Recv(tctx); id != -1 {
+		t.Fatalf("Recv after ctx done = %d", id)
+	}
+	s.Close()
+	if id, _, _ := s.Recv(ctx); id != -1 || s.Add(extra) != -1 {
+		t.Fatal("SelectAny not closed")
+	}
+}
+
+func TestMerger(t *testing.T) {
+	checkLeak(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMerger(ctx, generate(ctx, 10), generate(ctx, 10))
+	sum := 0
+	for i := 0; i < 20; i++ {
+		sum += <-m.Out()
+	}
+	if sum != 90 {
+		t.Fatalf("sum = %d, want 90", sum)
+	}
+	// 输入都关闭后 Out 不会关闭，还可以加入新的输入
+	waitLen(t, m.Len, 0)
+	m.Add(generate(ctx, 3))
+	for i := 0; i < 3; i++ {
+		if v := <-m.Out(); v != i {
+			t.Fatalf("got %d, want %d", v, i)
+		}
+	}
+
+	cancel()
+	if _, ok := <-m.Out(); ok {
+		t.Fatal("Out not closed after ctx done")
+	}
+
+	m = NewMerger[int](context.Background(), make(chan int))
+	m.Close()
+	if _, ok := <-m.Out(); ok {
+		t.Fatal("Out not closed after Close")
+	}
+}
@@ -0,0 +1,73 @@
+package chanx
+
+import (
+	"context"
+	"time"
+)
+
+// Debounce 防抖：输入安静 d 之后才输出最后一个元素，输入关闭时输出还没有输出的元素
+func Debounce[T any](ctx context.Context, in <-chan T, d time.Duration) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var (
+			last    T
+			pending bool
+			timer   = time.NewTimer(d)
+		)
+		defer timer.Stop()
+		stopTimer(timer)
+		for {
+			var fire <-chan time.Time
+			if pending {
+				fire = timer.C
+			}
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if pending {
+						send(ctx, out, last)
+					}
+					return
+				}
+				if pending {
+					stopTimer(timer)
+				}
+				last, pending = v, true
+				timer.Reset(d)
+			case <-fire:
+				pending = false
+				if !send(ctx, out, last) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Throttle 节流：每 d 时间内最多输出一个元素，输出窗口内的第一个元素，其余的丢弃
+func Throttle[T any](ctx context.Context, in <-chan T, d time.Duration) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var next time.Time // 下一个窗口的开始时间
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			now := time.Now()
+			if now.Before(next) { // 还在窗口内，丢弃
+				continue
+			}
+			next = now.Add(d)
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,91 @@
+// Package clock 可以替换的时钟，基于时间的并发原语通过它获取时间、创建 Timer 和 Ticker
+//
+// 生产环境使用 Real，直接调用 time 包；测试使用 Fake，时间只在调用 Advance 时前进，
+// 测试不需要真的等待，也不会因为机器繁忙而不稳定
+//
+// 使用时钟的原语：ticker.Ticker、pool.Pool、ChannelMutex.LockTimeout、
+// 1.basic/mutex/timeout、5.channel/trylock_timeout、1.basic/time/rand_ticker
+package clock
+
+import "time"
+
+// Clock 时间的来源，方法与 time 包中的同名函数一致
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 对应 *time.Timer，C 改成了方法；AfterFunc 返回的 Timer 的 C 为 nil
+// 与 Go 1.23 之后的 time.Timer 一致，Stop、Reset 返回之后不会再从 C 收到之前的时间，不需要调用者清空 C
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker 对应 *time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Real 使用 time 包的时钟
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+// realTimer、realTicker 的 Stop、Reset 丢弃已经触发但没有读取的时间，与 Fake 一致
+// go.mod 为 go 1.21，Go 1.23 到 1.26 的工具链按 Go 1.23 之前的语义编译，Stop、Reset 之后 C 中可能还留着之前的时间
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+
+func (t realTimer) Stop() bool {
+	active := t.t.Stop()
+	drain(t.t.C)
+	return active
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.t.Reset(d)
+	return active
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+	drain(t.t.C)
+}
+
+func (t realTicker) Reset(d time.Duration) {
+	t.Stop()
+	t.t.Reset(d)
+}
+
+// drain 丢弃 c 中已经到达的时间，AfterFunc 的 c 为 nil
+func drain(c <-chan time.Time) {
+	select {
+	case <-c:
+	default:
+	}
+}
@@ -0,0 +1,170 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTimer(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	t1 := c.NewTimer(2 * time.Second)
+	t2 := c.NewTimer(time.Second)
+
+	c.Advance(time.Second)
+	if got := <-t2.C(); !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("t2 fired at %v", got)
+	}
+	select {
+	case <-t1.C():
+		t.Fatal("t1 fired early")
+	default:
+	}
+	if c.Since(start) != time.Second {
+		t.Fatalf("Since = %v", c.Since(start))
+	}
+
+	if !t1.Stop() || t1.Stop() {
+		t.Fatal("Stop")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-t1.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	if t2.Reset(time.Second) {
+		t.Fatal("Reset of fired timer returned true")
+	}
+	done := make(chan struct{})
+	go func() {
+		c.BlockUntil(2)
+		close(done)
+	}()
+	c.NewTimer(time.Second)
+	<-done
+	c.Advance(time.Second)
+	<-t2.C()
+}
+
+func TestFakeTimerStaleValue(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	tm := c.NewTimer(time.Second)
+	c.Advance(time.Second) // 触发但没有读取
+	tm.Reset(2 * time.Second)
+	select {
+	case got := <-tm.C():
+		t.Fatalf("Reset delivered the old time %v", got)
+	default:
+	}
+	c.Advance(2 * time.Second)
+	if got := <-tm.C(); !got.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("fired at %v", got)
+	}
+
+	tm.Reset(0) // 立即触发
+	tm.Stop()
+	select {
+	case got := <-tm.C():
+		t.Fatalf("Stop left the old time %v", got)
+	default:
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	tm := Real.NewTimer(time.Millisecond)
+	<-tm.C()
+	if Real.Since(Real.Now()) < 0 {
+		t.Fatal("Since")
+	}
+
+	// 与 Fake 一致，Stop、Reset 之后不会收到之前的时间
+	tm.Reset(time.Millisecond)
+	time.Sleep(5 * time.Millisecond) // 触发但没有读取
+	tm.Reset(time.Hour)
+	select {
+	case got := <-tm.C():
+		t.Fatalf("Reset delivered the old time %v", got)
+	default:
+	}
+	tm.Reset(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	tm.Stop() // 返回值与工具链有关：Go 1.23 之后的语义下，还没有读取的时间被丢弃时也返回 true
+	select {
+	case got := <-tm.C():
+		t.Fatalf("Stop left the old time %v", got)
+	default:
+	}
+
+	tk := Real.NewTicker(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	tk.Stop()
+	select {
+	case got := <-tk.C():
+		t.Fatalf("Ticker.Stop left the old time %v", got)
+	default:
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	tk := c.NewTicker(time.Second)
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		if got := <-tk.C(); !got.Equal(start.Add(time.Duration(i) * time.Second)) {
+			t.Fatalf("tick %d at %v", i, got.Sub(start))
+		}
+	}
+	// 没有读取时与 time.Ticker 一致，只保留一个
+	c.Advance(3 * time.Second)
+	if got := <-tk.C(); !got.Equal(start.Add(4 * time.Second)) {
+		t.Fatalf("buffered tick at %v", got.Sub(start))
+	}
+	tk.Reset(time.Minute)
+	c.Advance(time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("tick before the new interval")
+	default:
+	}
+	tk.Stop()
+	if c.Waiters() != 0 {
+		t.Fatalf("Waiters = %d after Stop", c.Waiters())
+	}
+}
+
+func TestFakeSleepAfterFunc(t *testing.T) {
+	c := NewFake(time.Now())
+	fired := make(chan struct{})
+	c.AfterFunc(time.Second, func() { close(fired) })
+
+	woke := make(chan struct{})
+	go func() {
+		c.Sleep(2 * time.Second)
+		close(woke)
+	}()
+	c.BlockUntil(2)
+	c.Advance(time.Second)
+	<-fired
+	select {
+	case <-woke:
+		t.Fatal("Sleep returned early")
+	default:
+	}
+	c.Advance(time.Second)
+	<-woke
+
+	stopped := c.AfterFunc(time.Second, func() { t.Error("stopped AfterFunc ran") })
+	if !stopped.Stop() {
+		t.Fatal("Stop")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-c.After(0):
+	default:
+		t.Fatal("After(0) not ready")
+	}
+}
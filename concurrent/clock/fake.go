@@ -0,0 +1,181 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake 测试用的时钟，时间只在 Advance 时前进，零值不可用，使用 NewFake 创建
+// timer、ticker、After、Sleep 都登记为等待者，Advance 按到期时间的顺序触发它们
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer // 还没有触发的等待者，按到期时间排序
+	changed *sync.Cond   // timers 增加时广播
+}
+
+// NewFake 创建一个从 now 开始的时钟
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration { return f.Now().Sub(t) }
+
+func (f *Fake) After(d time.Duration) <-chan time.Time { return f.NewTimer(d).C() }
+
+// Sleep 阻塞到其它 goroutine 把时间推进 d
+func (f *Fake) Sleep(d time.Duration) { <-f.After(d) }
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(&fakeTimer{f: f, c: make(chan time.Time, 1)}, d)
+}
+
+// AfterFunc 到期时在新的 goroutine 中执行 fn
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.add(&fakeTimer{f: f, fn: fn}, d)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{f.add(&fakeTimer{f: f, c: make(chan time.Time, 1), period: d}, d)}
+}
+
+// Advance 时间前进 d，按到期时间的顺序触发到期的等待者
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for len(f.timers) > 0 && !f.timers[0].when.After(end) {
+		t := f.timers[0]
+		f.timers = f.timers[1:]
+		f.now = t.when
+		t.fire()
+		if t.period > 0 { // ticker 从这次的到期时间开始下一个周期
+			f.schedule(t, t.period)
+		}
+	}
+	f.now = end
+}
+
+// BlockUntil 阻塞到至少有 n 个还没有触发的等待者
+// 被测试的 goroutine 异步地创建、重置 timer，测试在 Advance 之前调用 BlockUntil，保证 Advance 能触发它们
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.timers) < n {
+		f.changed.Wait()
+	}
+}
+
+// Waiters 还没有触发的等待者的数量
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+func (f *Fake) add(t *fakeTimer, d time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(t, d)
+	return t
+}
+
+// schedule 加入 f.timers 并保持按到期时间排序，同时到期的按加入的顺序，已经到期的立即触发，需要持有 f.mu
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	t.when = f.now.Add(d)
+	if d <= 0 && t.period == 0 { // 与 time.Timer 一致，立即触发
+		t.fire()
+		return
+	}
+	i := sort.Search(len(f.timers), func(i int) bool { return f.timers[i].when.After(t.when) })
+	f.timers = append(f.timers, nil)
+	copy(f.timers[i+1:], f.timers[i:])
+	f.timers[i] = t
+	f.changed.Broadcast()
+}
+
+// unschedule 从 f.timers 中删除，返回 t 是否还没有触发，需要持有 f.mu
+func (f *Fake) unschedule(t *fakeTimer) bool {
+	for i, x := range f.timers {
+		if x == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	f      *Fake
+	c      chan time.Time
+	fn     func()        // AfterFunc
+	period time.Duration // ticker 的周期，timer 为 0
+	when   time.Time
+}
+
+func (t *fakeTimer) fire() {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	select { // 与 time.Timer、time.Ticker 一致，channel 的容量为 1，来不及读取时丢弃
+	case t.c <- t.when:
+	default:
+	}
+}
+
+// drain 丢弃已经触发但没有读取的时间，需要持有 f.mu
+// 与 Go 1.23 之后的 time.Timer 一致，Stop、Reset 返回之后不会再从 C 收到之前的时间
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.drain()
+	return t.f.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.drain()
+	active := t.f.unschedule(t)
+	t.f.schedule(t, d)
+	return active
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t *fakeTicker) C() <-chan time.Time { return t.t.c }
+func (t *fakeTicker) Stop()               { t.t.Stop() }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.t.f.mu.Lock()
+	defer t.t.f.mu.Unlock()
+	t.t.period = d
+	t.t.drain()
+	t.t.f.unschedule(t.t)
+	t.t.f.schedule(t.t, d)
+}
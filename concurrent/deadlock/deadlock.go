@@ -0,0 +1,401 @@
+// Package deadlock 提供 sync.Mutex、sync.RWMutex 的替代品，运行时检测潜在的死锁
+//
+// 开启检测：编译时加上 -tags deadlock，或者设置环境变量 GODEADLOCK=1，也可以调用 SetOptions
+// 未开启时 Mutex、RWMutex 直接调用 sync 的实现，只多一次判断
+//
+// 检测的内容
+//
+//	锁序：记录每个 goroutine 获取锁的顺序，持有 A 再获取 B 时，在锁图中加入 A -> B
+//	     加入新边后如果锁图中出现环，说明不同 goroutine 以不同的顺序获取同一组锁，可能死锁
+//	     例如 TestDeadlock 中的派出所证明和物业证明、哲学家就餐问题
+//	重入：同一个 goroutine 再次 Lock 已持有的 Mutex，一定会死锁（err_reentrant_lock）
+//	持有时间：持有锁超过 MaxHoldTime 时报告
+//
+// 报告中包含获取锁时的调用栈
+// 锁图会引用所有参与过检测的锁，开销也比较大，只适合在测试、集成测试中开启
+package deadlock
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/petermattis/goid"
+
+	"golang/concurrent/internal/stack"
+)
+
+// Kind 报告的类型
+type Kind int
+
+const (
+	LockOrder Kind = iota // 锁序不一致，锁图中出现环
+	Recursive             // 重入已持有的 Mutex
+	LongHold              // 持有锁的时间过长
+)
+
+func (k Kind) String() string {
+	switch k {
+	case LockOrder:
+		return "inconsistent lock ordering"
+	case Recursive:
+		return "recursive locking"
+	case LongHold:
+		return "lock held too long"
+	}
+	return "unknown"
+}
+
+// Report 一次检测到的问题
+type Report struct {
+	Kind      Kind
+	Goroutine int64  // 触发检测的 goroutine
+	Message   string // 包含调用栈的详细信息
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("POTENTIAL DEADLOCK: %s\n%s", r.Kind, r.Message)
+}
+
+// Options 检测的配置
+type Options struct {
+	Enabled     bool
+	MaxHoldTime time.Duration // 持有锁超过这个时长时报告，0 表示不检查
+	OnReport    func(*Report) // 为 nil 时输出到 os.Stderr
+}
+
+var opts atomic.Pointer[Options]
+
+func init() {
+	enabled := buildTag
+	switch os.Getenv("GODEADLOCK") {
+	case "1", "true":
+		enabled = true
+	case "0", "false":
+		enabled = false
+	}
+	opts.Store(&Options{Enabled: enabled, MaxHoldTime: 30 * time.Second})
+}
+
+// SetOptions 修改检测的配置，只影响之后获取的锁
+func SetOptions(o Options) {
+	opts.Store(&o)
+}
+
+// GetOptions 返回当前的配置
+func GetOptions() Options {
+	return *opts.Load()
+}
+
+// Reset 清空锁图和已报告的记录，一般在测试之间调用
+func Reset() {
+	det.mu.Lock()
+	defer det.mu.Unlock()
+	det.graph = map[any]map[any]*edge{}
+	det.reported = map[string]bool{}
+}
+
+func report(r *Report) {
+	if f := opts.Load().OnReport; f != nil {
+		f(r)
+		return
+	}
+	fmt.Fprintln(os.Stderr, r)
+}
+
+// ==========Mutex==========
+
+// Mutex 替代 sync.Mutex，零值可用
+type Mutex struct {
+	mu sync.Mutex
+}
+
+// Lock 请求锁
+func (m *Mutex) Lock() {
+	if !opts.Load().Enabled {
+		m.mu.Lock()
+		return
+	}
+	h := det.before(m, true)
+	m.mu.Lock()
+	det.after(h)
+}
+
+// TryLock 尝试获取锁，不会阻塞，所以不记录锁序
+func (m *Mutex) TryLock() bool {
+	if !opts.Load().Enabled {
+		return m.mu.TryLock()
+	}
+	if !m.mu.TryLock() {
+		return false
+	}
+	det.after(det.held(m, 0))
+	return true
+}
+
+// Unlock 释放锁
+func (m *Mutex) Unlock() {
+	if opts.Load().Enabled {
+		det.release(m)
+	}
+	m.mu.Unlock()
+}
+
+// ==========RWMutex==========
+
+// RWMutex 替代 sync.RWMutex，零值可用
+// 读锁也参与锁序检测：持有读锁时等待的 writer 会阻塞新的 reader，同样可能形成环
+type RWMutex struct {
+	mu sync.RWMutex
+}
+
+// Lock 请求写锁
+func (rw *RWMutex) Lock() {
+	if !opts.Load().Enabled {
+		rw.mu.Lock()
+		return
+	}
+	h := det.before(rw, true)
+	rw.mu.Lock()
+	det.after(h)
+}
+
+// TryLock 尝试获取写锁
+func (rw *RWMutex) TryLock() bool {
+	if !opts.Load().Enabled {
+		return rw.mu.TryLock()
+	}
+	if !rw.mu.TryLock() {
+		return false
+	}
+	det.after(det.held(rw, 0))
+	return true
+}
+
+// Unlock 释放写锁
+func (rw *RWMutex) Unlock() {
+	if opts.Load().Enabled {
+		det.release(rw)
+	}
+	rw.mu.Unlock()
+}
+
+// RLock 请求读锁，重入读锁不报告（没有 writer 等待时不会死锁，有 writer 等待时由锁序检测发现）
+func (rw *RWMutex) RLock() {
+	if !opts.Load().Enabled {
+		rw.mu.RLock()
+		return
+	}
+	h := det.before(rw, false)
+	rw.mu.RLock()
+	det.after(h)
+}
+
+// TryRLock 尝试获取读锁
+func (rw *RWMutex) TryRLock() bool {
+	if !opts.Load().Enabled {
+		return rw.mu.TryRLock()
+	}
+	if !rw.mu.TryRLock() {
+		return false
+	}
+	det.after(det.held(rw, 0))
+	return true
+}
+
+// RUnlock 释放读锁
+func (rw *RWMutex) RUnlock() {
+	if opts.Load().Enabled {
+		det.release(rw)
+	}
+	rw.mu.RUnlock()
+}
+
+// RLocker 返回读锁的 Locker
+func (rw *RWMutex) RLocker() sync.Locker {
+	return (*rlocker)(rw)
+}
+
+type rlocker RWMutex
+
+func (r *rlocker) Lock()   { (*RWMutex)(r).RLock() }
+func (r *rlocker) Unlock() { (*RWMutex)(r).RUnlock() }
+
+// ==========detector==========
+
+// hold 一次锁的持有
+type hold struct {
+	lock  any // *Mutex 或 *RWMutex
+	gid   int64
+	pcs   []uintptr
+	timer *time.Timer // 持有时间过长时报告
+}
+
+// edge 锁图中的一条边：持有 from 时获取 to
+type edge struct {
+	gid      int64
+	fromPCs  []uintptr // 获取 from 时的调用栈
+	toPCs    []uintptr // 获取 to 时的调用栈
+	from, to any
+}
+
+type detector struct {
+	mu       sync.Mutex
+	holds    map[int64][]*hold     // goroutine id -> 按获取顺序持有的锁
+	graph    map[any]map[any]*edge // from -> to -> 第一次出现时的边
+	reported map[string]bool       // 已经报告过的环，避免重复报告
+}
+
+var det = &detector{
+	holds:    map[int64][]*hold{},
+	graph:    map[any]map[any]*edge{},
+	reported: map[string]bool{},
+}
+
+// held 构造一次持有，还没有获取到锁，调用栈从 Mutex、RWMutex 的方法开始记录
+func (d *detector) held(lock any, skip int) *hold {
+	return &hold{lock: lock, gid: goid.Get(), pcs: stack.Callers(skip + 3)}
+}
+
+// before 获取锁之前检查锁序，这样真的死锁时也能先报告出来
+// exclusive 为 true 时重入已持有的锁一定会死锁
+func (d *detector) before(lock any, exclusive bool) *hold {
+	h := d.held(lock, 1)
+	var reports []*Report
+
+	d.mu.Lock()
+	for _, prev := range d.holds[h.gid] {
+		if prev.lock == lock {
+			if exclusive {
+				reports = append(reports, &Report{Kind: Recursive, Goroutine: h.gid, Message: fmt.Sprintf(
+					"goroutine %d locks %p again, previously acquired at:\n%s\nnow at:\n%s",
+					h.gid, lock, stack.Format(prev.pcs), stack.Format(h.pcs))})
+			}
+			continue
+		}
+		if r := d.addEdge(prev, h); r != nil {
+			reports = append(reports, r)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, r := range reports { // 不持有 d.mu 时回调，回调中可以使用锁
+		report(r)
+	}
+	return h
+}
+
+// after 获取到锁之后记录持有
+func (d *detector) after(h *hold) {
+	if limit := opts.Load().MaxHoldTime; limit > 0 {
+		h.timer = time.AfterFunc(limit, func() {
+			report(&Report{Kind: LongHold, Goroutine: h.gid, Message: fmt.Sprintf(
+				"goroutine %d has held %p for more than %v, acquired at:\n%s",
+				h.gid, h.lock, limit, stack.Format(h.pcs))})
+		})
+	}
+	d.mu.Lock()
+	d.holds[h.gid] = append(d.holds[h.gid], h)
+	d.mu.Unlock()
+}
+
+// release 释放锁时删除持有记录
+// sync.Mutex 允许其它 goroutine 释放锁，当前 goroutine 没有持有时去其它 goroutine 中找
+func (d *detector) release(lock any) {
+	gid := goid.Get()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.removeHold(gid, lock) {
+		return
+	}
+	for id := range d.holds {
+		if d.removeHold(id, lock) {
+			return
+		}
+	}
+}
+
+func (d *detector) removeHold(gid int64, lock any) bool {
+	hs := d.holds[gid]
+	for i := len(hs) - 1; i >= 0; i-- {
+		if hs[i].lock != lock {
+			continue
+		}
+		if hs[i].timer != nil {
+			hs[i].timer.Stop()
+		}
+		hs = append(hs[:i], hs[i+1:]...)
+		if len(hs) == 0 {
+			delete(d.holds, gid)
+		} else {
+			d.holds[gid] = hs
+		}
+		return true
+	}
+	return false
+}
+
+// addEdge 加入 prev.lock -> h.lock，如果形成环返回报告，需要持有 d.mu
+func (d *detector) addEdge(prev, h *hold) *Report {
+	tos, ok := d.graph[prev.lock]
+	if !ok {
+		tos = map[any]*edge{}
+		d.graph[prev.lock] = tos
+	}
+	if _, ok := tos[h.lock]; ok { // 已经检查过这个顺序
+		return nil
+	}
+	e := &edge{gid: h.gid, from: prev.lock, to: h.lock, fromPCs: prev.pcs, toPCs: h.pcs}
+	tos[h.lock] = e
+
+	path := d.path(h.lock, prev.lock, map[any]bool{})
+	if path == nil {
+		return nil
+	}
+	cycle := append([]*edge{e}, path...)
+	key := cycleKey(cycle)
+	if d.reported[key] {
+		return nil
+	}
+	d.reported[key] = true
+
+	var b strings.Builder
+	for _, c := range cycle {
+		fmt.Fprintf(&b, "goroutine %d acquired %p at:\n%s\nthen acquired %p at:\n%s\n",
+			c.gid, c.from, stack.Format(c.fromPCs), c.to, stack.Format(c.toPCs))
+	}
+	return &Report{Kind: LockOrder, Goroutine: h.gid, Message: b.String()}
+}
+
+// path 在锁图中查找 from 到 to 的路径（DFS）
+func (d *detector) path(from, to any, visited map[any]bool) []*edge {
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+	for next, e := range d.graph[from] {
+		if next == to {
+			return []*edge{e}
+		}
+		if p := d.path(next, to, visited); p != nil {
+			return append([]*edge{e}, p...)
+		}
+	}
+	return nil
+}
+
+// cycleKey 环的标识，与起点无关
+func cycleKey(cycle []*edge) string {
+	keys := make([]string, len(cycle))
+	start := 0
+	for i, e := range cycle {
+		keys[i] = fmt.Sprintf("%p", e.from)
+		if keys[i] < keys[start] {
+			start = i
+		}
+	}
+	return strings.Join(append(keys[start:], keys[:start]...), "->")
+}
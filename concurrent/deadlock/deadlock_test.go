@@ -0,0 +1,139 @@
+package deadlock
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collect 开启检测，收集报告
+func collect(t *testing.T, hold time.Duration) func() []*Report {
+	var (
+		mu      sync.Mutex
+		reports []*Report
+	)
+	old := GetOptions()
+	SetOptions(Options{Enabled: true, MaxHoldTime: hold, OnReport: func(r *Report) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	}})
+	Reset()
+	t.Cleanup(func() { SetOptions(old) })
+	return func() []*Report {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*Report(nil), reports...)
+	}
+}
+
+// TestLockOrder 派出所证明和物业证明：两个 goroutine 以不同的顺序获取锁
+// 这里先后执行，并不会真的死锁，但锁序检测能发现
+func TestLockOrder(t *testing.T) {
+	reports := collect(t, 0)
+	var ps, property Mutex
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ps.Lock()
+		property.Lock()
+		property.Unlock()
+		ps.Unlock()
+	}()
+	wg.Wait()
+	if rs := reports(); len(rs) != 0 {
+		t.Fatalf("unexpected reports: %v", rs)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		property.Lock()
+		ps.Lock()
+		ps.Unlock()
+		property.Unlock()
+	}()
+	wg.Wait()
+	rs := reports()
+	if len(rs) != 1 || rs[0].Kind != LockOrder {
+		t.Fatalf("got %v, want one lock order report", rs)
+	}
+	if !strings.Contains(rs[0].Message, "TestLockOrder") { // 包含调用栈
+		t.Fatalf("report without stacks: %v", rs[0])
+	}
+	t.Log(rs[0])
+}
+
+// TestDiningPhilosophers 哲学家就餐问题，每个哲学家先拿左手的叉子，形成一个长度为 5 的环
+func TestDiningPhilosophers(t *testing.T) {
+	reports := collect(t, 0)
+	forks := make([]RWMutex, 5)
+	for i := range forks {
+		left, right := &forks[i], &forks[(i+1)%len(forks)]
+		left.Lock()
+		right.Lock()
+		right.Unlock()
+		left.Unlock()
+	}
+	rs := reports()
+	if len(rs) != 1 || rs[0].Kind != LockOrder || strings.Count(rs[0].Message, "then acquired") != 5 {
+		t.Fatalf("got %v, want one 5-lock cycle", rs)
+	}
+}
+
+// TestRecursive 重入 Mutex，err_reentrant_lock
+func TestRecursive(t *testing.T) {
+	reports := collect(t, 0)
+	var mu Mutex
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mu.Lock()
+		mu.Lock() // 报告之后真的死锁，由测试 goroutine 释放
+		mu.Unlock()
+	}()
+	for len(reports()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	mu.Unlock()
+	<-done
+	if rs := reports(); rs[0].Kind != Recursive {
+		t.Fatalf("got %v, want recursive locking report", rs[0])
+	}
+}
+
+func TestLongHold(t *testing.T) {
+	reports := collect(t, 10*time.Millisecond)
+	var mu Mutex
+	mu.Lock()
+	time.Sleep(50 * time.Millisecond)
+	mu.Unlock()
+	rs := reports()
+	if len(rs) != 1 || rs[0].Kind != LongHold {
+		t.Fatalf("got %v, want one long hold report", rs)
+	}
+
+	mu.Lock() // 及时释放不报告
+	mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	if rs := reports(); len(rs) != 1 {
+		t.Fatalf("got %d reports, want 1", len(rs))
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	old := GetOptions()
+	SetOptions(Options{Enabled: false, OnReport: func(r *Report) { t.Errorf("unexpected report: %v", r) }})
+	defer SetOptions(old)
+	var a, b Mutex
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+}
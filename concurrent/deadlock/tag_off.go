@@ -0,0 +1,5 @@
+//go:build !deadlock
+
+package deadlock
+
+const buildTag = false
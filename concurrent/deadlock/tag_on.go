@@ -0,0 +1,6 @@
+//go:build deadlock
+
+package deadlock
+
+// 使用 go test -tags deadlock 编译时默认开启检测
+const buildTag = true
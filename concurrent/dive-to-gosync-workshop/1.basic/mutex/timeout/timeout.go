@@ -2,14 +2,28 @@ package main
 
 import (
 	"time"
+
+	"golang/concurrent/clock"
 )
 
 type Mutex struct {
-	ch chan struct{}
+	ch    chan struct{}
+	clock clock.Clock // TryLock 计时使用的时钟
 }
 
-func NewMutex() *Mutex {
-	mu := &Mutex{make(chan struct{}, 1)}
+// Option NewMutex 的选项
+type Option func(*Mutex)
+
+// WithClock TryLock 使用 c 计时，测试时可以传入 clock.Fake，默认为 clock.Real
+func WithClock(c clock.Clock) Option {
+	return func(m *Mutex) { m.clock = c }
+}
+
+func NewMutex(opts ...Option) *Mutex {
+	mu := &Mutex{ch: make(chan struct{}, 1), clock: clock.Real}
+	for _, opt := range opts {
+		opt(mu)
+	}
 	mu.ch <- struct{}{}
 	return mu
 }
@@ -24,12 +38,12 @@ func (m *Mutex) Unlock() {
 	}
 }
 func (m *Mutex) TryLock(timeout time.Duration) bool {
-	timer := time.NewTimer(timeout)
+	timer := m.clock.NewTimer(timeout)
 	select {
 	case <-m.ch:
 		timer.Stop()
 		return true
-	case <-timer.C:
+	case <-timer.C():
 	}
 	return false
 }
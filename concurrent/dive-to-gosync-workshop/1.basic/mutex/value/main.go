@@ -1,42 +1,32 @@
 package main
 
-import "sync"
+import (
+	"errors"
+	"fmt"
 
-// https://github.com/carlmjohnson/syncx/blob/main/mutex.go
+	"golang/concurrent/guarded"
+)
 
-type Mutex[T any] struct {
-	mu    sync.RWMutex
-	value T
-}
+// 最初的版本来自 https://github.com/carlmjohnson/syncx/blob/main/mutex.go
 
-func NewMutex[T any](initial T) *Mutex[T] {
-	var m Mutex[T]
-	m.value = initial
-	return &m
-}
+func main() {
+	m := guarded.NewRWMutex(map[string]int{})
+	changes, cancel := m.Subscribe(8)
+	defer cancel()
 
-func (m *Mutex[T]) ReadLock(f func(value T)) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	f(m.value)
-}
+	m.Lock(func(v *map[string]int) { (*v)["a"] = 1 })
+	m.ReadLock(func(v map[string]int) { fmt.Println("a =", v["a"]) })
 
-func (m *Mutex[T]) Lock(f func(value *T)) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	value := m.value
-	f(&value)
-	m.value = value
-}
+	// f 返回错误时不写入 f 返回的值，也不会通知订阅者
+	// f 收到的 map 就是当前的值，在 f 中修改它不会被回滚，所以 f 只能返回新的 map，不能修改参数
+	_, err := m.Update(func(v map[string]int) (map[string]int, error) {
+		return nil, errors.New("invalid")
+	})
+	fmt.Println("update:", err, m.Load())
 
-func (m *Mutex[T]) Load() T {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.value
-}
+	n := guarded.NewMutex(1)
+	fmt.Println("cas 1->2:", guarded.CompareAndSwap[int](n, 1, 2), n.Load())
+	fmt.Println("cas 1->3:", guarded.CompareAndSwap[int](n, 1, 3), n.Load())
 
-func (m *Mutex[T]) Store(value T) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.value = value
+	fmt.Println("changed:", <-changes)
 }
@@ -4,6 +4,8 @@ import (
 	"log"
 	"math/rand"
 	"time"
+
+	"golang/concurrent/clock"
 )
 
 func main() {
@@ -18,30 +20,44 @@ func main() {
 	}
 }
 
+// RandTicker 间隔为 d±variance 的 Ticker
 type RandTicker struct {
-	C    <-chan time.Time
-	done chan struct{}
+	C     <-chan time.Time
+	done  chan struct{}
+	clock clock.Clock
 }
 
-func NewRandTicker(d, variance time.Duration) *RandTicker {
-	c := make(chan time.Time, 1)
-	done := make(chan struct{})
+// Option NewRandTicker 的选项
+type Option func(*RandTicker)
 
-	go start(d, variance, c, done)
+// WithClock 使用 c 计时，测试时可以传入 clock.Fake，默认为 clock.Real
+func WithClock(c clock.Clock) Option {
+	return func(t *RandTicker) { t.clock = c }
+}
 
-	return &RandTicker{
-		C:    c,
-		done: done,
+func NewRandTicker(d, variance time.Duration, opts ...Option) *RandTicker {
+	ch := make(chan time.Time, 1)
+	t := &RandTicker{
+		C:     ch,
+		done:  make(chan struct{}),
+		clock: clock.Real,
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
+
+	go start(t.clock, d, variance, ch, t.done)
+
+	return t
 }
 
-func start(d, variance time.Duration, c chan time.Time, done chan struct{}) {
+func start(clk clock.Clock, d, variance time.Duration, c chan time.Time, done chan struct{}) {
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 	for {
 		vr := time.Duration(rnd.Int63n(int64(2*variance)) - int64(variance))
-		tmr := time.NewTimer(d + vr) // ±variance
+		tmr := clk.NewTimer(d + vr) // ±variance
 		select {
-		case firedAt := <-tmr.C:
+		case firedAt := <-tmr.C():
 			select {
 			case c <- firedAt:
 			default:
@@ -1,33 +1,42 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
-	"github.com/reugn/async"
+	"golang/concurrent/future"
 )
 
+// Future/Promise 的实现在 golang/concurrent/future 中，不再依赖 github.com/reugn/async
 func main() {
-	p := async.NewPromise()
+	ctx := context.Background()
+
+	p := future.NewPromise[bool]()
 	go func() {
 		time.Sleep(time.Millisecond * 100)
-		p.Success(true)
+		p.Resolve(true)
 	}()
-	v, e := p.Future().Get()
+	v, e := p.Future().Get(ctx)
 	fmt.Println(v, e)
 
-	p1 := async.NewPromise()
-	p2 := async.NewPromise()
-	p3 := async.NewPromise()
+	p1 := future.NewPromise[int]()
+	p2 := future.NewPromise[int]()
+	p3 := future.NewPromise[int]()
 	go func() {
 		time.Sleep(time.Millisecond * 100)
-		p1.Success(1)
+		p1.Resolve(1)
 		time.Sleep(time.Millisecond * 200)
-		p2.Success(2)
+		p2.Resolve(2)
 		time.Sleep(time.Millisecond * 300)
-		p3.Success(3)
+		p3.Resolve(3)
 	}()
 
-	v, e = async.FutureSeq([]async.Future{p1.Future(), p2.Future(), p3.Future()}).Get()
-	fmt.Println(v, e)
+	// 对应 async.FutureSeq
+	vs, e := future.All(p1.Future(), p2.Future(), p3.Future()).Get(ctx)
+	fmt.Println(vs, e)
+
+	// 生产者 panic 时转换为错误
+	_, e = future.Go(func() (int, error) { panic("boom") }).Timeout(time.Second).Get(ctx)
+	fmt.Println(e != nil)
 }
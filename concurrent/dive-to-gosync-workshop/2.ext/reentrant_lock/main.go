@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang/concurrent"
+)
+
+// 可重入锁的实现统一放在 golang/concurrent 中：ReentrantMutex、ReentrantRWMutex、TokenRecursiveMutex
+func main() {
+	var mu concurrent.ReentrantMutex
+	foo(&mu)
+
+	// 误用时的错误信息中包含持有者的 goroutine id 和获取锁时的调用栈
+	mu.Lock()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := mu.LockContext(ctx); err != nil {
+			fmt.Println(err)
+		}
+
+		defer func() { fmt.Println(recover()) }()
+		mu.Unlock() // 非持有者释放锁
+	}()
+	time.Sleep(time.Second)
+	mu.Unlock()
+}
+
+func foo(mu *concurrent.ReentrantMutex) {
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Println("in foo")
+	bar(mu)
+}
+
+func bar(mu *concurrent.ReentrantMutex) {
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Println("in bar")
+}
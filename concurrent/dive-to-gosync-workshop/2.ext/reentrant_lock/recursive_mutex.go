@@ -1,45 +0,0 @@
-package main
-
-import (
-	"fmt"
-	"sync"
-	"sync/atomic"
-
-	"github.com/petermattis/goid"
-)
-
-func main() {
-
-}
-
-// RecursiveLock aka. ReentrantLock
-type RecursiveMutex struct {
-	sync.Mutex
-	owner     int64
-	recursion int32
-}
-
-func (m *RecursiveMutex) Lock() {
-	gid := goid.Get()
-	if atomic.LoadInt64(&m.owner) == gid {
-		m.recursion++
-		return
-	}
-	m.Mutex.Lock()
-	// we are now inside the lock
-	atomic.StoreInt64(&m.owner, gid)
-	m.recursion = 1
-}
-
-func (m *RecursiveMutex) Unlock() {
-	gid := goid.Get()
-	if atomic.LoadInt64(&m.owner) != gid {
-		panic(fmt.Sprintf("wrong the owner(%d): %d!", m.owner, gid))
-	}
-	m.recursion--
-	if m.recursion != 0 {
-		return
-	}
-	atomic.StoreInt64(&m.owner, -1)
-	m.Mutex.Unlock()
-}
@@ -1,22 +1,62 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"net"
 
-	"github.com/fatih/pool"
+	"golang/concurrent/pool"
 )
 
+// 原来使用 github.com/fatih/pool 连接 baidu.com:80，现在使用 concurrent/pool.ConnPool 连接本地的 echo 服务器
 func main() {
-	factory := func() (net.Conn, error) { return net.Dial("tcp", "baidu.com:80") }
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					c.Write([]byte(line))
+				}
+			}()
+		}
+	}()
 
-	p, err := pool.NewChannelPool(5, 30, factory)
+	var d net.Dialer
+	p, err := pool.NewConnPool(pool.ConnConfig{
+		Dial: func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		MaxIdle: 5,
+		MaxOpen: 30,
+	})
 	if err != nil {
 		panic(err)
 	}
 
-	conn, err := p.Get()
-	conn.Close()
+	addr := ln.Addr().String()
+	conn, err := p.Get(context.Background(), addr)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(conn, "hello")
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	fmt.Print("echo: ", line)
+	conn.Close() // 放回池中，并不会真正关闭
 
-	fmt.Println(p.Len())
+	fmt.Printf("%+v\n", p.Stats()[addr])
+	fmt.Println(p.Close(context.Background()))
 }
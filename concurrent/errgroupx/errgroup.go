@@ -0,0 +1,169 @@
+// Package errgroupx 扩展的 errgroup，source/18.errgroup.go 中 errgroup.Group 的泛型版本
+//
+// errgroup.Group 只保留第一个错误，子任务 panic 时整个进程崩溃，子任务的结果要自己通过 channel 或者共享变量收集
+// Group[T] 在保留 WithContext、SetLimit、TryGo 的基础上：
+//
+//	Go(func() (T, error))，Wait 按 Go 调用的顺序返回所有子任务的结果 []T
+//	CollectErrors：收集所有的错误，Wait 返回 errors.Join 的结果；默认与 errgroup 一致，只返回第一个错误
+//	RecoverPanics：子任务的 panic 被恢复为 *future.PanicError，带有调用栈；默认与 errgroup 一致，不恢复
+package errgroupx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"golang/concurrent/future"
+)
+
+type token struct{}
+
+type options struct {
+	collect bool
+	recover bool
+}
+
+// Option Group 的选项
+type Option func(*options)
+
+// CollectErrors Wait 返回所有子任务的错误（按 Go 调用的顺序 errors.Join），
+// 一个子任务失败时不取消 ctx，其它子任务继续执行，ctx 在 Wait 返回时取消
+func CollectErrors() Option {
+	return func(o *options) { o.collect = true }
+}
+
+// RecoverPanics 子任务 panic 时恢复，作为这个子任务的错误 *future.PanicError
+func RecoverPanics() Option {
+	return func(o *options) { o.recover = true }
+}
+
+// Group 一组执行同一个任务的子任务的 goroutine，每个子任务返回一个 T
+//
+// 零值可用：不限制 goroutine 的数量，只返回第一个错误，不恢复 panic
+type Group[T any] struct {
+	opts   options
+	cancel func(error)
+
+	wg  sync.WaitGroup
+	sem chan token
+
+	mu      sync.Mutex
+	results []T
+	errs    []error // 与 results 一一对应
+	err     error   // 第一个错误
+}
+
+// New 创建一个 Group
+func New[T any](opts ...Option) *Group[T] {
+	g := &Group[T]{}
+	for _, opt := range opts {
+		opt(&g.opts)
+	}
+	return g
+}
+
+// WithContext 创建一个 Group 和从 ctx 派生的 Context
+// 派生的 Context 在第一个子任务失败（CollectErrors 时不会）或者 Wait 第一次返回时被取消
+func WithContext[T any](ctx context.Context, opts ...Option) (*Group[T], context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := New[T](opts...)
+	g.cancel = cancel
+	return g, ctx
+}
+
+// Go 在新的 goroutine 中执行 f，达到 SetLimit 的上限时阻塞
+func (g *Group[T]) Go(f func() (T, error)) {
+	if g.sem != nil {
+		g.sem <- token{}
+	}
+	g.start(f)
+}
+
+// TryGo 没有达到 SetLimit 的上限时在新的 goroutine 中执行 f，返回是否执行
+func (g *Group[T]) TryGo(f func() (T, error)) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- token{}:
+		default:
+			return false
+		}
+	}
+	g.start(f)
+	return true
+}
+
+// SetLimit 限制同时执行的子任务最多为 n 个，负数表示不限制；有子任务在执行时不能修改
+func (g *Group[T]) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan token, n)
+}
+
+// Wait 等待所有的子任务返回，返回按 Go 调用的顺序排列的结果和错误
+// 失败的子任务在结果中是零值；默认返回第一个错误，CollectErrors 时返回所有的错误
+func (g *Group[T]) Wait() ([]T, error) {
+	g.wg.Wait()
+	g.mu.Lock()
+	results := append([]T(nil), g.results...)
+	err := g.err
+	if g.opts.collect {
+		err = errors.Join(g.errs...)
+	}
+	g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel(err)
+	}
+	return results, err
+}
+
+func (g *Group[T]) start(f func() (T, error)) {
+	g.mu.Lock()
+	i := len(g.results)
+	var zero T
+	g.results = append(g.results, zero)
+	g.errs = append(g.errs, nil)
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		v, err := g.call(f)
+
+		g.mu.Lock()
+		g.results[i] = v
+		g.errs[i] = err
+		first := err != nil && g.err == nil
+		if first {
+			g.err = err
+		}
+		g.mu.Unlock()
+		if first && !g.opts.collect && g.cancel != nil {
+			g.cancel(err)
+		}
+	}()
+}
+
+func (g *Group[T]) call(f func() (T, error)) (v T, err error) {
+	if g.opts.recover {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &future.PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return f()
+}
+
+func (g *Group[T]) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
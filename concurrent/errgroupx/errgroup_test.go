@@ -0,0 +1,125 @@
+package errgroupx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang/concurrent/future"
+)
+
+func TestResults(t *testing.T) {
+	var g Group[int] // 零值可用
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func() (int, error) { return i * i, nil })
+	}
+	results, err := g.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range results {
+		if v != i*i {
+			t.Fatalf("results[%d] = %d", i, v)
+		}
+	}
+}
+
+func TestFirstError(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	g, ctx := WithContext[int](context.Background())
+	g.Go(func() (int, error) { return 0, errA })
+	g.Go(func() (int, error) {
+		<-ctx.Done() // 第一个错误取消 ctx
+		return 0, errB
+	})
+	g.Go(func() (int, error) { return 3, nil })
+	results, err := g.Wait()
+	if err != errA {
+		t.Fatalf("err = %v", err)
+	}
+	if !errors.Is(context.Cause(ctx), errA) {
+		t.Fatalf("cause = %v", context.Cause(ctx))
+	}
+	if results[2] != 3 {
+		t.Fatalf("results = %v", results)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	g, ctx := WithContext[string](context.Background(), CollectErrors())
+	g.Go(func() (string, error) { return "", errA })
+	g.Go(func() (string, error) { return "ok", nil })
+	g.Go(func() (string, error) {
+		if ctx.Err() != nil { // 失败不取消 ctx，其它子任务继续
+			return "", ctx.Err()
+		}
+		return "", errB
+	})
+	results, err := g.Wait()
+	if !errors.Is(err, errA) || !errors.Is(err, errB) || err.Error() != "a\nb" {
+		t.Fatalf("err = %v", err)
+	}
+	if results[1] != "ok" {
+		t.Fatalf("results = %q", results)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("ctx not canceled after Wait")
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	errBoom := errors.New("boom")
+	g := New[int](RecoverPanics(), CollectErrors())
+	g.Go(func() (int, error) { panic(errBoom) })
+	g.Go(func() (int, error) { panic("oops") })
+	g.Go(func() (int, error) { return 1, nil })
+	results, err := g.Wait()
+	var pe *future.PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, errBoom) || !strings.Contains(err.Error(), "oops") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(pe.Stack) == 0 {
+		t.Fatal("no stack")
+	}
+	if results[2] != 1 {
+		t.Fatalf("results = %v", results)
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	var g Group[int]
+	g.SetLimit(2)
+	var active atomic.Int32
+	entered := make(chan int32)
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		g.Go(func() (int, error) {
+			entered <- active.Add(1)
+			<-release
+			active.Add(-1)
+			return 0, nil
+		})
+	}
+	<-entered
+	if n := <-entered; n != 2 {
+		t.Fatalf("active = %d", n)
+	}
+	if g.TryGo(func() (int, error) { return 0, nil }) {
+		t.Fatal("TryGo succeeded over limit")
+	}
+	close(release)
+	if _, err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !g.TryGo(func() (int, error) { return 7, nil }) {
+		t.Fatal("TryGo failed under limit")
+	}
+	results, _ := g.Wait()
+	if len(results) != 3 || results[2] != 7 {
+		t.Fatalf("results = %v", results)
+	}
+}
@@ -0,0 +1,307 @@
+// Package future 泛型的 Future/Promise，用于组合多个并发的调用
+//
+// Promise 由生产者持有，只能完成一次（Resolve 或者 Reject）；Future 由消费者持有，只读
+// 组合函数（Map、Then、Recover、All、Any、Race、Timeout）不会为每个 Future 常驻一个 goroutine：
+// 回调登记在源 Future 上，源完成时才启动 goroutine 执行，源永远不完成也不会泄漏
+//
+// 生产者或者回调 panic 时，panic 被转换为 *PanicError，作为 Future 的错误
+//
+// Go 的方法不能有类型参数，所以改变结果类型的 Map、Then 是函数而不是方法
+package future
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrTimeout Timeout 超时的错误
+var ErrTimeout = errors.New("future: timeout")
+
+// PanicError 生产者或者回调 panic 时的错误
+type PanicError struct {
+	Value any    // recover() 的返回值
+	Stack []byte // panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("future: panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap panic 的值是 error 时，errors.Is、errors.As 可以检查它
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// ==========Future==========
+
+// Future 一个将来才会得到的结果
+type Future[T any] struct {
+	done chan struct{}
+
+	mu        sync.Mutex
+	completed bool
+	callbacks []func()
+
+	v   T     // done 关闭之后只读
+	err error // done 关闭之后只读
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Get 等待结果，直到完成或者 ctx 结束
+// ctx 结束时返回 ctx.Err()，Future 本身不受影响，之后还可以再 Get
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.v, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 完成时被关闭
+func (f *Future[T]) Done() <-chan struct{} { return f.done }
+
+// complete 设置结果，只有第一次调用有效
+func (f *Future[T]) complete(v T, err error) bool {
+	f.mu.Lock()
+	if f.completed {
+		f.mu.Unlock()
+		return false
+	}
+	f.completed = true
+	f.v, f.err = v, err
+	callbacks := f.callbacks
+	f.callbacks = nil
+	close(f.done)
+	f.mu.Unlock()
+
+	for _, cb := range callbacks {
+		go cb() // 回调可能很慢，不阻塞完成 Future 的生产者
+	}
+	return true
+}
+
+// onComplete 完成之后在新的 goroutine 中执行 cb
+func (f *Future[T]) onComplete(cb func()) {
+	f.mu.Lock()
+	if !f.completed {
+		f.callbacks = append(f.callbacks, cb)
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+	go cb()
+}
+
+// ==========Promise==========
+
+// Promise 写入 Future 的一端
+type Promise[T any] struct {
+	f *Future[T]
+}
+
+// NewPromise 创建一个 Promise
+func NewPromise[T any]() *Promise[T] {
+	return &Promise[T]{f: newFuture[T]()}
+}
+
+// Future 与 Promise 关联的 Future
+func (p *Promise[T]) Future() *Future[T] { return p.f }
+
+// Resolve 以结果 v 完成，已经完成时返回 false
+func (p *Promise[T]) Resolve(v T) bool { return p.f.complete(v, nil) }
+
+// Reject 以错误 err 完成，已经完成时返回 false
+func (p *Promise[T]) Reject(err error) bool {
+	var zero T
+	return p.f.complete(zero, err)
+}
+
+// ==========构造==========
+
+// Go 在新的 goroutine 中执行 fn，返回它的结果
+func Go[T any](fn func() (T, error)) *Future[T] {
+	f := newFuture[T]()
+	go func() {
+		f.complete(call(fn))
+	}()
+	return f
+}
+
+// Resolved 已经以 v 完成的 Future
+func Resolved[T any](v T) *Future[T] {
+	f := newFuture[T]()
+	f.complete(v, nil)
+	return f
+}
+
+// Rejected 已经以 err 完成的 Future
+func Rejected[T any](err error) *Future[T] {
+	f := newFuture[T]()
+	var zero T
+	f.complete(zero, err)
+	return f
+}
+
+// call 执行 fn，把 panic 转换为 *PanicError
+func call[T any](fn func() (T, error)) (v T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// ==========组合==========
+
+// Map f 成功后用 fn 转换结果，f 失败时直接传递错误
+func Map[T, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	out := newFuture[U]()
+	f.onComplete(func() {
+		if f.err != nil {
+			var zero U
+			out.complete(zero, f.err)
+			return
+		}
+		out.complete(call(func() (U, error) { return fn(f.v) }))
+	})
+	return out
+}
+
+// Then f 成功后用 fn 发起下一个异步调用，结果为下一个调用的结果
+func Then[T, U any](f *Future[T], fn func(T) *Future[U]) *Future[U] {
+	out := newFuture[U]()
+	f.onComplete(func() {
+		if f.err != nil {
+			var zero U
+			out.complete(zero, f.err)
+			return
+		}
+		next, err := call(func() (*Future[U], error) { return fn(f.v), nil })
+		if err != nil {
+			var zero U
+			out.complete(zero, err)
+			return
+		}
+		next.onComplete(func() { out.complete(next.v, next.err) })
+	})
+	return out
+}
+
+// Recover f 失败后用 fn 处理错误，可以返回替代的结果，也可以返回新的错误
+func (f *Future[T]) Recover(fn func(error) (T, error)) *Future[T] {
+	out := newFuture[T]()
+	f.onComplete(func() {
+		if f.err == nil {
+			out.complete(f.v, nil)
+			return
+		}
+		out.complete(call(func() (T, error) { return fn(f.err) }))
+	})
+	return out
+}
+
+// Timeout d 之内 f 没有完成时以 ErrTimeout 失败
+func (f *Future[T]) Timeout(d time.Duration) *Future[T] {
+	out := newFuture[T]()
+	timer := time.AfterFunc(d, func() {
+		var zero T
+		out.complete(zero, ErrTimeout)
+	})
+	f.onComplete(func() {
+		timer.Stop()
+		out.complete(f.v, f.err)
+	})
+	return out
+}
+
+// All 所有 Future 都成功后，按顺序返回它们的结果；任意一个失败时立即以它的错误失败
+func All[T any](fs ...*Future[T]) *Future[[]T] {
+	out := newFuture[[]T]()
+	if len(fs) == 0 {
+		out.complete([]T{}, nil)
+		return out
+	}
+	var (
+		mu      sync.Mutex
+		results = make([]T, len(fs))
+		left    = len(fs)
+	)
+	for i, f := range fs {
+		i, f := i, f
+		f.onComplete(func() {
+			if f.err != nil {
+				out.complete(nil, f.err)
+				return
+			}
+			mu.Lock()
+			results[i] = f.v
+			left--
+			finished := left == 0
+			mu.Unlock()
+			if finished {
+				out.complete(results, nil)
+			}
+		})
+	}
+	return out
+}
+
+// Any 返回第一个成功的结果；全部失败时以所有错误的 errors.Join 失败
+func Any[T any](fs ...*Future[T]) *Future[T] {
+	out := newFuture[T]()
+	if len(fs) == 0 {
+		var zero T
+		out.complete(zero, errors.New("future: Any of no futures"))
+		return out
+	}
+	var (
+		mu   sync.Mutex
+		errs = make([]error, len(fs))
+		left = len(fs)
+	)
+	for i, f := range fs {
+		i, f := i, f
+		f.onComplete(func() {
+			if f.err == nil {
+				out.complete(f.v, nil)
+				return
+			}
+			mu.Lock()
+			errs[i] = f.err
+			left--
+			finished := left == 0
+			mu.Unlock()
+			if finished {
+				var zero T
+				out.complete(zero, errors.Join(errs...))
+			}
+		})
+	}
+	return out
+}
+
+// Race 返回第一个完成的结果，无论成功还是失败；没有 Future 时立即失败
+func Race[T any](fs ...*Future[T]) *Future[T] {
+	out := newFuture[T]()
+	if len(fs) == 0 {
+		var zero T
+		out.complete(zero, errors.New("future: Race of no futures"))
+		return out
+	}
+	for _, f := range fs {
+		f := f
+		f.onComplete(func() { out.complete(f.v, f.err) })
+	}
+	return out
+}
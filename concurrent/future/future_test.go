@@ -0,0 +1,133 @@
+package future
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPromise(t *testing.T) {
+	p := NewPromise[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Future().Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get before Resolve: %v", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.Resolve(1)
+	}()
+	if v, err := p.Future().Get(context.Background()); v != 1 || err != nil {
+		t.Fatalf("Get = %d, %v", v, err)
+	}
+	if p.Resolve(2) || p.Reject(errors.New("late")) {
+		t.Fatal("completed twice")
+	}
+}
+
+func TestGoPanic(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	_, err := Go(func() (int, error) { panic(sentinel) }).Get(context.Background())
+	var pe *PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, sentinel) || len(pe.Stack) == 0 {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 回调中的 panic 同样转换为错误
+	_, err = Map(Resolved(1), func(int) (int, error) { panic("boom") }).Get(context.Background())
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("Map err = %v", err)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	s, err := Map(Resolved(42), func(v int) (string, error) { return strconv.Itoa(v), nil }).Get(ctx)
+	if s != "42" || err != nil {
+		t.Fatalf("Map = %q, %v", s, err)
+	}
+	if _, err := Map(Rejected[int](failed), func(v int) (int, error) { return v, nil }).Get(ctx); err != failed {
+		t.Fatalf("Map of rejected = %v", err)
+	}
+
+	n, err := Then(Resolved("7"), func(s string) *Future[int] {
+		return Go(func() (int, error) { return strconv.Atoi(s) })
+	}).Get(ctx)
+	if n != 7 || err != nil {
+		t.Fatalf("Then = %d, %v", n, err)
+	}
+
+	n, err = Rejected[int](failed).Recover(func(err error) (int, error) { return -1, nil }).Get(ctx)
+	if n != -1 || err != nil {
+		t.Fatalf("Recover = %d, %v", n, err)
+	}
+
+	never := NewPromise[int]().Future()
+	if _, err := never.Timeout(10 * time.Millisecond).Get(ctx); err != ErrTimeout {
+		t.Fatalf("Timeout = %v", err)
+	}
+	if v, err := Resolved(1).Timeout(time.Second).Get(ctx); v != 1 || err != nil {
+		t.Fatalf("Timeout of resolved = %d, %v", v, err)
+	}
+}
+
+func TestAllAnyRace(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+	after := func(d time.Duration, v int, err error) *Future[int] {
+		return Go(func() (int, error) {
+			time.Sleep(d)
+			return v, err
+		})
+	}
+
+	vs, err := All(after(20*time.Millisecond, 1, nil), after(0, 2, nil), Resolved(3)).Get(ctx)
+	if fmt.Sprint(vs) != "[1 2 3]" || err != nil {
+		t.Fatalf("All = %v, %v", vs, err)
+	}
+	// 任意一个失败时立即失败，不等待其它的
+	start := time.Now()
+	if _, err := All(after(time.Second, 1, nil), after(0, 0, failed)).Get(ctx); err != failed || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("All with failure = %v after %v", err, time.Since(start))
+	}
+
+	if v, err := Any(after(0, 0, failed), after(20*time.Millisecond, 2, nil)).Get(ctx); v != 2 || err != nil {
+		t.Fatalf("Any = %d, %v", v, err)
+	}
+	other := errors.New("other")
+	if _, err := Any(Rejected[int](failed), Rejected[int](other)).Get(ctx); !errors.Is(err, failed) || !errors.Is(err, other) {
+		t.Fatalf("Any all failed = %v", err)
+	}
+
+	if _, err := Race(after(time.Second, 1, nil), after(0, 0, failed)).Get(ctx); err != failed {
+		t.Fatalf("Race = %v", err)
+	}
+	if _, err := Race[int]().Get(ctx); err == nil {
+		t.Fatal("Race of no futures should fail")
+	}
+}
+
+// 与 begin/02.concurrent 中的 AllResponse、FirstResponse 相同的场景，没有 goroutine 阻塞在无缓冲的 channel 上
+func TestAllFirstResponse(t *testing.T) {
+	call := func(id int) *Future[string] {
+		return Go(func() (string, error) {
+			time.Sleep(time.Duration(id) * time.Millisecond)
+			return fmt.Sprintf("response from %d", id), nil
+		})
+	}
+	ctx := context.Background()
+
+	all, err := All(call(3), call(1), call(2)).Get(ctx)
+	if fmt.Sprint(all) != "[response from 3 response from 1 response from 2]" || err != nil {
+		t.Fatalf("All = %v, %v", all, err)
+	}
+	first, err := Race(call(30), call(1), call(20)).Timeout(time.Second).Get(ctx)
+	if first != "response from 1" || err != nil {
+		t.Fatalf("Race = %q, %v", first, err)
+	}
+}
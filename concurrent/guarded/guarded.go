@@ -0,0 +1,300 @@
+// Package guarded 由锁保护的值，1.basic/mutex/value 中 Mutex[T] 的完整版本
+//
+// 值只能通过方法访问，不会忘记加锁，也不会在锁外持有指向值内部的指针（除非 f 把它泄漏出去）
+//
+//	Mutex[T]   sync.Mutex 保护，读写都是互斥的
+//	RWMutex[T] sync.RWMutex 保护，ReadLock、Load 可以并发
+//	COW[T]     写时复制，值保存在 atomic.Pointer 中，Load 不加锁，适合读多写少的配置（AtomicValueConfig）
+//
+// 写入
+//
+//	Store 直接替换；Lock 在锁内修改；Update 由 f 返回新的值，f 返回错误时丢弃返回的值，不写入
+//	回滚只针对 f 的返回值：f 收到的是当前的值本身，T 含有 map、slice、指针时，f 对它们的原地修改不会撤销，
+//	所以 f 不能修改它的参数，需要修改时先复制，再返回复制后的值
+//	CompareAndSwap 对 comparable 的 T 原子地比较并交换
+//
+// 订阅
+//
+//	Subscribe 返回的 channel 在每次写入之后收到新的值（Update 回滚、CompareAndSwap 失败不算写入）
+//	通知在锁内按写入的顺序发送，不会阻塞写入：订阅者来不及读取时丢弃最旧的通知，最后收到的一定是最新的值
+package guarded
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errMismatch CompareAndSwap 中旧值不相等，回滚 Update
+var errMismatch = errors.New("guarded: value mismatch")
+
+// Updater 可以通过 Update 修改的值，Mutex、RWMutex、COW 都实现了这个接口
+type Updater[T any] interface {
+	Update(f func(T) (T, error)) (T, error)
+}
+
+// CompareAndSwap 值等于 old 时替换为 new，返回是否替换
+func CompareAndSwap[T comparable](u Updater[T], old, new T) bool {
+	_, err := u.Update(func(v T) (T, error) {
+		if v != old {
+			return v, errMismatch
+		}
+		return new, nil
+	})
+	return err == nil
+}
+
+// ==========notifier==========
+
+// notifier 管理订阅者，notify 在值的写锁内调用，保证通知的顺序与写入的顺序一致
+type notifier[T any] struct {
+	mu   sync.Mutex // 保护 subs，订阅、取消不需要获取值的锁
+	subs map[chan T]struct{}
+}
+
+func (n *notifier[T]) subscribe(buffer int) (<-chan T, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan T, buffer)
+	n.mu.Lock()
+	if n.subs == nil {
+		n.subs = make(map[chan T]struct{})
+	}
+	n.subs[ch] = struct{}{}
+	n.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			n.mu.Lock()
+			delete(n.subs, ch)
+			close(ch)
+			n.mu.Unlock()
+		})
+	}
+}
+
+// notify 把 v 发送给所有订阅者，缓冲满时丢弃最旧的通知
+func (n *notifier[T]) notify(v T) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subs {
+		for {
+			select {
+			case ch <- v:
+			default:
+				select {
+				case <-ch: // 丢弃最旧的，再试一次
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// ==========Mutex==========
+
+// Mutex 由 sync.Mutex 保护的值
+type Mutex[T any] struct {
+	mu    sync.Mutex
+	value T
+	n     notifier[T]
+}
+
+// NewMutex 创建一个初始值为 initial 的 Mutex
+func NewMutex[T any](initial T) *Mutex[T] {
+	return &Mutex[T]{value: initial}
+}
+
+// Lock 在锁内执行 f，f 可以修改值
+func (m *Mutex[T]) Lock(f func(value *T)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value := m.value
+	f(&value)
+	m.value = value
+	m.n.notify(value)
+}
+
+// Update 在锁内执行 f，f 返回新的值；f 返回错误时不写入，f 不能原地修改参数，见包文档
+// 返回更新后的值，失败时返回原来的值和 f 的错误
+func (m *Mutex[T]) Update(f func(T) (T, error)) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := f(m.value)
+	if err != nil {
+		return m.value, err
+	}
+	m.value = v
+	m.n.notify(v)
+	return v, nil
+}
+
+func (m *Mutex[T]) Load() T {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.value
+}
+
+func (m *Mutex[T]) Store(value T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.value = value
+	m.n.notify(value)
+}
+
+// Swap 替换为 value，返回原来的值
+func (m *Mutex[T]) Swap(value T) (old T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, m.value = m.value, value
+	m.n.notify(value)
+	return old
+}
+
+// Subscribe 订阅之后的每次写入，buffer 为通知的缓冲大小（至少为 1），调用 cancel 取消订阅并关闭 channel
+func (m *Mutex[T]) Subscribe(buffer int) (<-chan T, func()) {
+	return m.n.subscribe(buffer)
+}
+
+// ==========RWMutex==========
+
+// RWMutex 由 sync.RWMutex 保护的值，读操作可以并发
+type RWMutex[T any] struct {
+	mu    sync.RWMutex
+	value T
+	n     notifier[T]
+}
+
+// NewRWMutex 创建一个初始值为 initial 的 RWMutex
+func NewRWMutex[T any](initial T) *RWMutex[T] {
+	return &RWMutex[T]{value: initial}
+}
+
+// ReadLock 在读锁内执行 f，f 不能修改值（包括值引用的 map、slice）
+func (m *RWMutex[T]) ReadLock(f func(value T)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f(m.value)
+}
+
+// Lock 在写锁内执行 f，f 可以修改值
+func (m *RWMutex[T]) Lock(f func(value *T)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value := m.value
+	f(&value)
+	m.value = value
+	m.n.notify(value)
+}
+
+// Update 在写锁内执行 f，f 返回新的值；f 返回错误时不写入，f 不能原地修改参数，见包文档
+func (m *RWMutex[T]) Update(f func(T) (T, error)) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := f(m.value)
+	if err != nil {
+		return m.value, err
+	}
+	m.value = v
+	m.n.notify(v)
+	return v, nil
+}
+
+func (m *RWMutex[T]) Load() T {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.value
+}
+
+func (m *RWMutex[T]) Store(value T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.value = value
+	m.n.notify(value)
+}
+
+// Swap 替换为 value，返回原来的值
+func (m *RWMutex[T]) Swap(value T) (old T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, m.value = m.value, value
+	m.n.notify(value)
+	return old
+}
+
+// Subscribe 订阅之后的每次写入
+func (m *RWMutex[T]) Subscribe(buffer int) (<-chan T, func()) {
+	return m.n.subscribe(buffer)
+}
+
+// ==========COW==========
+
+// COW 写时复制的值：Load 只是一次原子读取，写入在锁内生成新的值再原子地替换
+// T 中的 map、slice 等引用类型在写入之后不能再修改，Update 的 f 需要复制之后再修改
+// 与 Mutex、RWMutex 一样零值可用，初始值为 T 的零值
+type COW[T any] struct {
+	mu sync.Mutex // 串行化写入
+	p  atomic.Pointer[T]
+	n  notifier[T]
+}
+
+// NewCOW 创建一个初始值为 initial 的 COW
+func NewCOW[T any](initial T) *COW[T] {
+	c := &COW[T]{}
+	c.p.Store(&initial)
+	return c
+}
+
+// Load 读取当前的值，不加锁
+func (c *COW[T]) Load() T {
+	return deref(c.p.Load())
+}
+
+func (c *COW[T]) Store(value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.p.Store(&value)
+	c.n.notify(value)
+}
+
+// Update f 根据当前的值返回新的值，f 返回错误时不写入，f 不能原地修改参数
+// 写入之间是串行的，不会丢失并发的更新
+func (c *COW[T]) Update(f func(T) (T, error)) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := deref(c.p.Load())
+	v, err := f(old)
+	if err != nil {
+		return old, err
+	}
+	c.p.Store(&v)
+	c.n.notify(v)
+	return v, nil
+}
+
+// Swap 替换为 value，返回原来的值
+func (c *COW[T]) Swap(value T) (old T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old = deref(c.p.Swap(&value))
+	c.n.notify(value)
+	return old
+}
+
+// deref 零值的 COW 还没有存入过值，p 为 nil
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// Subscribe 订阅之后的每次写入
+func (c *COW[T]) Subscribe(buffer int) (<-chan T, func()) {
+	return c.n.subscribe(buffer)
+}
@@ -0,0 +1,169 @@
+package guarded
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type value[T any] interface {
+	Updater[T]
+	Load() T
+	Store(T)
+	Swap(T) T
+	Subscribe(buffer int) (<-chan T, func())
+}
+
+func eachKind(t *testing.T, f func(t *testing.T, v value[int])) {
+	t.Run("Mutex", func(t *testing.T) { f(t, NewMutex(0)) })
+	t.Run("RWMutex", func(t *testing.T) { f(t, NewRWMutex(0)) })
+	t.Run("COW", func(t *testing.T) { f(t, NewCOW(0)) })
+}
+
+func TestUpdateRollback(t *testing.T) {
+	eachKind(t, func(t *testing.T, v value[int]) {
+		v.Store(1)
+		errBoom := errors.New("boom")
+		got, err := v.Update(func(x int) (int, error) { return x + 100, errBoom })
+		if !errors.Is(err, errBoom) || got != 1 || v.Load() != 1 {
+			t.Fatalf("Update = %d, %v; Load = %d", got, err, v.Load())
+		}
+		got, err = v.Update(func(x int) (int, error) { return x + 1, nil })
+		if err != nil || got != 2 || v.Load() != 2 {
+			t.Fatalf("Update = %d, %v; Load = %d", got, err, v.Load())
+		}
+		if old := v.Swap(5); old != 2 || v.Load() != 5 {
+			t.Fatalf("Swap = %d; Load = %d", old, v.Load())
+		}
+	})
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	eachKind(t, func(t *testing.T, v value[int]) {
+		if CompareAndSwap[int](v, 1, 2) {
+			t.Fatal("CompareAndSwap succeeded on mismatch")
+		}
+		if !CompareAndSwap[int](v, 0, 2) || v.Load() != 2 {
+			t.Fatalf("CompareAndSwap failed, Load = %d", v.Load())
+		}
+
+		// 并发的 CAS 自增不会丢失更新
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					for {
+						old := v.Load()
+						if CompareAndSwap[int](v, old, old+1) {
+							break
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if got := v.Load(); got != 802 {
+			t.Fatalf("Load = %d, want 802", got)
+		}
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	eachKind(t, func(t *testing.T, v value[int]) {
+		ch, cancel := v.Subscribe(4)
+		v.Store(1)
+		_, _ = v.Update(func(x int) (int, error) { return 0, errors.New("rollback") }) // 回滚不通知
+		CompareAndSwap[int](v, 100, 0)                                                 // 失败不通知
+		CompareAndSwap[int](v, 1, 2)
+		v.Swap(3)
+		for _, want := range []int{1, 2, 3} {
+			if got := <-ch; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+		}
+		select {
+		case got := <-ch:
+			t.Fatalf("unexpected notification %d", got)
+		default:
+		}
+
+		// 来不及读取时丢弃最旧的，最后一个一定是最新的值
+		for i := 10; i < 20; i++ {
+			v.Store(i)
+		}
+		var last int
+		for n := 0; n < 4; n++ {
+			last = <-ch
+		}
+		if last != 19 {
+			t.Fatalf("last = %d, want 19", last)
+		}
+
+		cancel()
+		cancel()
+		if _, ok := <-ch; ok {
+			t.Fatal("channel not closed after cancel")
+		}
+		v.Store(100) // 取消之后不再通知
+	})
+}
+
+func TestLock(t *testing.T) {
+	m := NewMutex(map[string]int{})
+	rw := NewRWMutex(map[string]int{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Lock(func(v *map[string]int) { (*v)["n"]++ })
+				rw.Lock(func(v *map[string]int) { (*v)["n"]++ })
+				rw.ReadLock(func(v map[string]int) { _ = v["n"] })
+			}
+		}()
+	}
+	wg.Wait()
+	m.Lock(func(v *map[string]int) {
+		if (*v)["n"] != 800 {
+			t.Errorf("Mutex n = %d", (*v)["n"])
+		}
+	})
+	rw.ReadLock(func(v map[string]int) {
+		if v["n"] != 800 {
+			t.Errorf("RWMutex n = %d", v["n"])
+		}
+	})
+}
+
+func TestCOWSnapshot(t *testing.T) {
+	type config struct {
+		Addr  string
+		Peers []string
+	}
+	c := NewCOW(config{Addr: "a", Peers: []string{"p1"}})
+	old := c.Load()
+	_, _ = c.Update(func(v config) (config, error) {
+		v.Peers = append(append([]string(nil), v.Peers...), "p2") // 复制之后再修改
+		return v, nil
+	})
+	if len(old.Peers) != 1 || len(c.Load().Peers) != 2 {
+		t.Fatalf("old = %+v, new = %+v", old, c.Load())
+	}
+}
+
+func TestCOWZeroValue(t *testing.T) {
+	var c COW[int]
+	if v := c.Load(); v != 0 {
+		t.Fatalf("Load = %d", v)
+	}
+	if v, err := c.Update(func(v int) (int, error) { return v + 1, nil }); v != 1 || err != nil {
+		t.Fatalf("Update = %d, %v", v, err)
+	}
+	var d COW[int]
+	if old := d.Swap(2); old != 0 || d.Load() != 2 {
+		t.Fatalf("Swap = %d, Load = %d", old, d.Load())
+	}
+}
@@ -0,0 +1,31 @@
+// Package stack 记录和格式化调用栈，可重入锁和死锁检测在诊断信息中使用
+package stack
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Callers 记录调用栈的 PC，跳过 skip 层，与 runtime.Callers 的 skip 含义相同
+func Callers(skip int) []uintptr {
+	pcs := make([]uintptr, 32)
+	return pcs[:runtime.Callers(skip, pcs)]
+}
+
+// Format 把 PC 解析成调用栈，每一帧占两行：函数名、文件和行号
+func Format(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
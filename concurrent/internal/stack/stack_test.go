@@ -0,0 +1,16 @@
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallers(t *testing.T) {
+	s := Format(Callers(2)) // 跳过 runtime.Callers 和 Callers
+	if !strings.HasPrefix(s, "\tgolang/concurrent/internal/stack.TestCallers\n") {
+		t.Fatalf("stack = %q", s)
+	}
+	if Format(nil) != "" {
+		t.Fatal("empty stack should format as empty string")
+	}
+}
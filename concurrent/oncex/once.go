@@ -0,0 +1,191 @@
+// Package oncex 扩展的 Once，把 08.once.go 和 dive-to-gosync-workshop/1.basic/once 中的各种变体合并到一起
+//
+//	Once         可以 Reset 的 sync.Once
+//	OnceErr      f 返回 error，失败时不算执行过，下次 Do 重试，直到成功（08.once.go 中的 Once）
+//	OnceValue[T] 只初始化一次的值，可以设置 TTL，过期后重新初始化（MuOnce 想要实现的功能）
+//
+// 都提供 Done 方法，返回是否已经成功执行过（issue go#41690）
+//
+// panic 的语义
+//
+//	Once：与 sync.Once 一致，f panic 也算执行过，panic 传递给这次 Do 的调用者，之后的 Do 不会再执行 f
+//	OnceErr、OnceValue：f panic 与返回 error 一样算失败，panic 传递给这次的调用者，下次调用重试
+//	在 f 中（直接或间接）调用同一个对象的 Do、Get、Reset 会死锁，这里检测出来直接 panic
+//
+// Reset 与 Do 并发
+//
+//	Reset 会等待正在执行的 f 结束后再重置，不会出现 Docker Once panic 中 Unlock 一个未加锁的 Mutex 的问题
+//	Reset 之前完成的 Do 看到的是旧的结果，Reset 之后的 Do 会重新执行 f
+package oncex
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/petermattis/goid"
+)
+
+// guard 执行 f 的互斥锁，记录执行 f 的 goroutine，用于检测在 f 中重入
+type guard struct {
+	mu     sync.Mutex
+	runner atomic.Int64 // 正在执行 f 的 goroutine id
+}
+
+// lock 获取锁，op 为调用的方法名，在 f 中重入时 panic
+func (g *guard) lock(op string) {
+	if id := g.runner.Load(); id != 0 && id == goid.Get() {
+		panic("oncex: " + op + " called from the function being executed, it would deadlock")
+	}
+	g.mu.Lock()
+}
+
+func (g *guard) unlock() { g.mu.Unlock() }
+
+// run 在持有锁时执行 f
+func (g *guard) run(f func()) {
+	g.runner.Store(goid.Get())
+	defer g.runner.Store(0)
+	f()
+}
+
+// ==========Once==========
+
+// Once 可以 Reset 的 sync.Once，零值可用
+type Once struct {
+	done atomic.Bool
+	g    guard
+}
+
+// Do 第一次调用（或者 Reset 之后的第一次调用）时执行 f
+func (o *Once) Do(f func()) {
+	if o.done.Load() { // fast path
+		return
+	}
+	o.doSlow(f)
+}
+
+func (o *Once) doSlow(f func()) {
+	o.g.lock("Do")
+	defer o.g.unlock()
+	if o.done.Load() {
+		return
+	}
+	defer o.done.Store(true) // f panic 也算执行过
+	o.g.run(f)
+}
+
+// Done 是否已经执行过，正在执行时返回 false
+func (o *Once) Done() bool { return o.done.Load() }
+
+// Reset 重置为未执行的状态，正在执行 f 时等待它结束
+func (o *Once) Reset() {
+	o.g.lock("Reset")
+	defer o.g.unlock()
+	o.done.Store(false)
+}
+
+// ==========OnceErr==========
+
+// OnceErr f 成功之前每次 Do 都会执行 f，零值可用
+type OnceErr struct {
+	done atomic.Bool
+	g    guard
+}
+
+// Do 还没有成功执行过时执行 f，返回 f 的错误；已经成功执行过时返回 nil
+func (o *OnceErr) Do(f func() error) error {
+	if o.done.Load() {
+		return nil
+	}
+	return o.doSlow(f)
+}
+
+func (o *OnceErr) doSlow(f func() error) (err error) {
+	o.g.lock("Do")
+	defer o.g.unlock()
+	if o.done.Load() { // 等待期间其它 goroutine 已经成功了
+		return nil
+	}
+	o.g.run(func() { err = f() })
+	if err == nil { // f panic 时不会执行到这里
+		o.done.Store(true)
+	}
+	return err
+}
+
+// Done 是否已经成功执行过
+func (o *OnceErr) Done() bool { return o.done.Load() }
+
+// Reset 重置为未执行的状态，正在执行 f 时等待它结束
+func (o *OnceErr) Reset() {
+	o.g.lock("Reset")
+	defer o.g.unlock()
+	o.done.Store(false)
+}
+
+// ==========OnceValue==========
+
+// onceResult 一次成功的初始化结果，初始化之后不再修改
+type onceResult[T any] struct {
+	v       T
+	expires time.Time // 零值表示不过期
+}
+
+// OnceValue 只初始化一次的值，使用 NewOnceValue 创建
+type OnceValue[T any] struct {
+	f   func() (T, error)
+	ttl time.Duration
+	res atomic.Pointer[onceResult[T]] // 为 nil 表示还没有成功初始化，或者已经 Reset
+	g   guard
+}
+
+// NewOnceValue 创建一个由 f 初始化的值
+// ttl > 0 时，成功初始化 ttl 之后过期，下一次 Get 重新执行 f；ttl <= 0 时永不过期
+func NewOnceValue[T any](f func() (T, error), ttl time.Duration) *OnceValue[T] {
+	return &OnceValue[T]{f: f, ttl: ttl}
+}
+
+// Get 返回初始化的值，还没有初始化或者已经过期时执行 f
+// f 失败时返回它的错误，不缓存，下一次 Get 重试
+func (o *OnceValue[T]) Get() (T, error) {
+	if r := o.res.Load(); r != nil && !r.expired(time.Now()) { // fast path
+		return r.v, nil
+	}
+	return o.getSlow()
+}
+
+func (o *OnceValue[T]) getSlow() (v T, err error) {
+	o.g.lock("Get")
+	defer o.g.unlock()
+	if r := o.res.Load(); r != nil && !r.expired(time.Now()) { // 等待期间其它 goroutine 已经初始化了
+		return r.v, nil
+	}
+	o.g.run(func() { v, err = o.f() })
+	if err != nil {
+		return v, err
+	}
+	r := &onceResult[T]{v: v}
+	if o.ttl > 0 {
+		r.expires = time.Now().Add(o.ttl)
+	}
+	o.res.Store(r)
+	return v, nil
+}
+
+// Done 是否持有一个没有过期的值
+func (o *OnceValue[T]) Done() bool {
+	r := o.res.Load()
+	return r != nil && !r.expired(time.Now())
+}
+
+// Reset 丢弃当前的值，下一次 Get 重新执行 f；正在执行 f 时等待它结束
+func (o *OnceValue[T]) Reset() {
+	o.g.lock("Reset")
+	defer o.g.unlock()
+	o.res.Store(nil)
+}
+
+func (r *onceResult[T]) expired(now time.Time) bool {
+	return !r.expires.IsZero() && !now.Before(r.expires)
+}
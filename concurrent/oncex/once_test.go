@@ -0,0 +1,204 @@
+package oncex
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mustPanic 返回 f panic 的值
+func mustPanic(t *testing.T, f func()) (p any) {
+	t.Helper()
+	defer func() {
+		if p = recover(); p == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	f()
+	return nil
+}
+
+func TestOnce(t *testing.T) {
+	var (
+		o  Once
+		n  atomic.Int32
+		wg sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.Do(func() { n.Add(1) })
+		}()
+	}
+	wg.Wait()
+	if n.Load() != 1 || !o.Done() {
+		t.Fatalf("executed %d times", n.Load())
+	}
+	o.Reset()
+	if o.Done() {
+		t.Fatal("Done after Reset")
+	}
+	o.Do(func() { n.Add(1) })
+	if n.Load() != 2 {
+		t.Fatalf("executed %d times after Reset", n.Load())
+	}
+
+	// 与 sync.Once 一致，panic 也算执行过
+	var p Once
+	mustPanic(t, func() { p.Do(func() { panic("boom") }) })
+	p.Do(func() { t.Fatal("executed again after panic") })
+	if !p.Done() {
+		t.Fatal("not Done after panic")
+	}
+}
+
+func TestOnceErr(t *testing.T) {
+	var (
+		o     OnceErr
+		calls int
+	)
+	failed := errors.New("failed")
+	f := func() error {
+		calls++
+		if calls < 3 {
+			return failed
+		}
+		return nil
+	}
+	if o.Do(f) != failed || o.Do(f) != failed || o.Done() {
+		t.Fatal("failure counted as done")
+	}
+	if o.Do(f) != nil || !o.Done() || o.Do(f) != nil || calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+
+	// panic 算失败，下次重试
+	var p OnceErr
+	mustPanic(t, func() { p.Do(func() error { panic("boom") }) })
+	if p.Done() || p.Do(func() error { return nil }) != nil || !p.Done() {
+		t.Fatal("panic counted as done")
+	}
+}
+
+func TestOnceValue(t *testing.T) {
+	var calls atomic.Int32
+	v := NewOnceValue(func() (int32, error) { return calls.Add(1), nil }, 20*time.Millisecond)
+	if x, _ := v.Get(); x != 1 {
+		t.Fatalf("Get = %d", x)
+	}
+	if x, _ := v.Get(); x != 1 || !v.Done() {
+		t.Fatalf("Get = %d, want cached 1", x)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v.Done() {
+		t.Fatal("Done after ttl")
+	}
+	if x, _ := v.Get(); x != 2 {
+		t.Fatalf("Get after ttl = %d, want 2", x)
+	}
+	v.Reset()
+	if x, _ := v.Get(); x != 3 {
+		t.Fatalf("Get after Reset = %d, want 3", x)
+	}
+
+	// 错误和 panic 都不缓存
+	failed := errors.New("failed")
+	fail := true
+	e := NewOnceValue(func() (string, error) {
+		if fail {
+			return "", failed
+		}
+		return "ok", nil
+	}, 0)
+	if _, err := e.Get(); err != failed || e.Done() {
+		t.Fatalf("Get = %v", err)
+	}
+	fail = false
+	if s, err := e.Get(); s != "ok" || err != nil {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	p := NewOnceValue(func() (int, error) { panic("boom") }, 0)
+	mustPanic(t, func() { p.Get() })
+	mustPanic(t, func() { p.Get() })
+}
+
+func TestReentrant(t *testing.T) {
+	var o Once
+	msg := mustPanic(t, func() { o.Do(func() { o.Reset() }) })
+	if !strings.Contains(msg.(string), "Reset") {
+		t.Fatalf("panic = %v", msg)
+	}
+	var e OnceErr
+	mustPanic(t, func() { e.Do(func() error { return e.Do(func() error { return nil }) }) })
+
+	var v *OnceValue[int]
+	v = NewOnceValue(func() (int, error) { return v.Get() }, 0)
+	mustPanic(t, func() { v.Get() })
+}
+
+// Reset 与 Do 并发：Reset 等待正在执行的 f 结束，之后的 Do 重新执行 f
+func TestResetDuringDo(t *testing.T) {
+	var (
+		o       Once
+		started = make(chan struct{})
+		release = make(chan struct{})
+		calls   atomic.Int32
+	)
+	go o.Do(func() {
+		calls.Add(1)
+		close(started)
+		<-release
+	})
+	<-started
+
+	reset := make(chan struct{})
+	go func() {
+		o.Reset()
+		close(reset)
+	}()
+	select {
+	case <-reset:
+		t.Fatal("Reset did not wait for f")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-reset
+	if o.Done() {
+		t.Fatal("Done after Reset")
+	}
+	o.Do(func() { calls.Add(1) })
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+
+	// 压力测试：任意时刻 Reset 都不会破坏状态，也不会 panic
+	var (
+		v  = NewOnceValue(func() (int, error) { return 1, nil }, 0)
+		wg sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if x, err := v.Get(); x != 1 || err != nil {
+					t.Errorf("Get = %d, %v", x, err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				v.Reset()
+				o.Reset()
+				o.Do(func() {})
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,204 @@
+// Package phaser 可以动态增减参与者的循环栅栏，参考 Java 的 java.util.concurrent.Phaser
+//
+// source/17.cyclicbarrier.go 中的 CyclicBarrier 参与者的数量在创建时固定，每一轮都必须是同样多的 goroutine
+// Phaser 的参与者可以随时 Register 加入、ArriveAndDeregister 退出，每一轮称为一个阶段（phase），从 0 开始编号：
+//
+//	所有注册的参与者都到达时进入下一个阶段，在放行之前调用 onAdvance，它返回 true 时 Phaser 终止
+//	默认的 onAdvance 在参与者全部退出时终止
+//	Arrive 只到达不等待；ArriveAndAwait 到达并等待其它参与者；AwaitPhase 不是参与者也可以等待某个阶段结束
+//	等待可以通过 ctx 取消或者超时，取消时已经到达的依然算作到达，不会像 CyclicBarrier 那样破坏整个栅栏
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTerminated Phaser 已经终止
+var ErrTerminated = errors.New("phaser: terminated")
+
+type config struct {
+	onAdvance func(phase, parties int) bool
+}
+
+// Option Phaser 的选项
+type Option func(*config)
+
+// WithOnAdvance 每个阶段结束时，在放行等待者之前调用 f，phase 为结束的阶段，parties 为注册的参与者的数量；
+// f 返回 true 时 Phaser 终止。f 在持有内部锁时调用，不能调用 Phaser 的方法
+func WithOnAdvance(f func(phase, parties int) bool) Option {
+	return func(cfg *config) { cfg.onAdvance = f }
+}
+
+// Phaser 动态参与者的多阶段栅栏
+type Phaser struct {
+	onAdvance func(phase, parties int) bool
+
+	mu         sync.Mutex
+	phase      int
+	parties    int
+	arrived    int
+	terminated bool
+	advanced   chan struct{} // 当前阶段结束或者终止时关闭
+}
+
+// New 创建一个有 parties 个参与者的 Phaser，parties 可以为 0，之后再 Register
+func New(parties int, opts ...Option) *Phaser {
+	if parties < 0 {
+		panic("phaser: negative parties")
+	}
+	cfg := config{onAdvance: func(phase, parties int) bool { return parties == 0 }}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Phaser{
+		onAdvance: cfg.onAdvance,
+		parties:   parties,
+		advanced:  make(chan struct{}),
+	}
+}
+
+// Register 增加一个参与者，返回它加入的阶段
+func (p *Phaser) Register() (int, error) {
+	return p.RegisterN(1)
+}
+
+// RegisterN 增加 n 个参与者，返回它们加入的阶段
+func (p *Phaser) RegisterN(n int) (int, error) {
+	if n < 0 {
+		panic("phaser: negative registration")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.terminated {
+		return p.phase, ErrTerminated
+	}
+	p.parties += n
+	return p.phase, nil
+}
+
+// Arrive 到达当前阶段但不等待其它参与者，返回到达的阶段
+func (p *Phaser) Arrive() (int, error) {
+	return p.arrive(false)
+}
+
+// ArriveAndDeregister 到达当前阶段并退出，之后的阶段不再等待它，返回到达的阶段
+func (p *Phaser) ArriveAndDeregister() (int, error) {
+	return p.arrive(true)
+}
+
+// ArriveAndAwait 到达当前阶段并等待其它参与者到达，返回进入的下一个阶段
+// ctx 结束时返回 ctx.Err()，这个参与者依然算作已经到达
+func (p *Phaser) ArriveAndAwait(ctx context.Context) (int, error) {
+	phase, err := p.arrive(false)
+	if err != nil {
+		return phase, err
+	}
+	return p.AwaitPhase(ctx, phase)
+}
+
+// AwaitPhase 等待 phase 阶段结束，返回进入的下一个阶段；当前已经不是 phase 阶段时立即返回当前阶段
+// 不需要是注册的参与者
+func (p *Phaser) AwaitPhase(ctx context.Context, phase int) (int, error) {
+	p.mu.Lock()
+	if p.phase != phase || p.terminated {
+		cur, terminated := p.phase, p.terminated
+		p.mu.Unlock()
+		if terminated && cur == phase {
+			return cur, ErrTerminated
+		}
+		return cur, nil
+	}
+	advanced := p.advanced
+	p.mu.Unlock()
+
+	select {
+	case <-advanced:
+	case <-ctx.Done():
+		return phase, ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.terminated && p.phase == phase {
+		return p.phase, ErrTerminated
+	}
+	return phase + 1, nil
+}
+
+// ForceTermination 终止 Phaser，所有的等待者返回 ErrTerminated，之后的 Register 和 Arrive 失败
+func (p *Phaser) ForceTermination() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.terminate()
+}
+
+// Phase 当前的阶段
+func (p *Phaser) Phase() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+// Parties 注册的参与者的数量
+func (p *Phaser) Parties() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.parties
+}
+
+// Arrived 当前阶段已经到达的参与者的数量
+func (p *Phaser) Arrived() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.arrived
+}
+
+// IsTerminated 是否已经终止
+func (p *Phaser) IsTerminated() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.terminated
+}
+
+func (p *Phaser) arrive(deregister bool) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase := p.phase
+	if p.terminated {
+		return phase, ErrTerminated
+	}
+	if p.arrived >= p.parties {
+		panic("phaser: arrive called more than the number of registered parties")
+	}
+	if deregister {
+		p.parties--
+	} else {
+		p.arrived++
+	}
+	if p.arrived == p.parties {
+		p.advance()
+	}
+	return phase, nil
+}
+
+// advance 所有的参与者都已到达，进入下一个阶段，需要持有 p.mu
+func (p *Phaser) advance() {
+	if p.onAdvance(p.phase, p.parties) {
+		p.terminate()
+		return
+	}
+	p.phase++
+	p.arrived = 0
+	close(p.advanced)
+	p.advanced = make(chan struct{})
+}
+
+// terminate 需要持有 p.mu
+func (p *Phaser) terminate() {
+	if p.terminated {
+		return
+	}
+	p.terminated = true
+	close(p.advanced)
+}
@@ -0,0 +1,145 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPhases(t *testing.T) {
+	const parties, phases = 5, 10
+	var mu sync.Mutex
+	var trace []int // 每个参与者在每个阶段记录阶段号，阶段之间不能交错
+	p := New(parties)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parties; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for phase := 0; phase < phases; phase++ {
+				mu.Lock()
+				trace = append(trace, phase)
+				mu.Unlock()
+				next, err := p.ArriveAndAwait(context.Background())
+				if err != nil || next != phase+1 {
+					t.Errorf("ArriveAndAwait = %d, %v", next, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, phase := range trace {
+		if phase != i/parties {
+			t.Fatalf("trace = %v", trace)
+		}
+	}
+	if p.Phase() != phases {
+		t.Fatalf("Phase = %d", p.Phase())
+	}
+}
+
+func TestDynamicParties(t *testing.T) {
+	p := New(1)
+	if phase, err := p.Register(); phase != 0 || err != nil {
+		t.Fatalf("Register = %d, %v", phase, err)
+	}
+	if _, err := p.Arrive(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Phase() != 0 || p.Arrived() != 1 {
+		t.Fatalf("phase = %d, arrived = %d", p.Phase(), p.Arrived())
+	}
+	// 第二个参与者退出，阶段 0 结束
+	if phase, _ := p.ArriveAndDeregister(); phase != 0 || p.Phase() != 1 || p.Parties() != 1 {
+		t.Fatalf("phase = %d, parties = %d", p.Phase(), p.Parties())
+	}
+	// 只剩一个参与者，Arrive 直接进入下一个阶段
+	p.Arrive()
+	if p.Phase() != 2 {
+		t.Fatalf("phase = %d", p.Phase())
+	}
+	// 最后一个参与者退出，默认的 onAdvance 终止
+	p.ArriveAndDeregister()
+	if !p.IsTerminated() {
+		t.Fatal("not terminated after all parties deregistered")
+	}
+	if _, err := p.Register(); !errors.Is(err, ErrTerminated) {
+		t.Fatalf("Register after termination = %v", err)
+	}
+}
+
+func TestAwaitPhase(t *testing.T) {
+	p := New(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.AwaitPhase(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AwaitPhase timeout = %v", err)
+	}
+
+	done := make(chan int)
+	go func() {
+		next, _ := p.AwaitPhase(context.Background(), 0) // 不是参与者也可以等待
+		done <- next
+	}()
+	p.Arrive()
+	select {
+	case <-done:
+		t.Fatal("AwaitPhase returned before all parties arrived")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.Arrive()
+	if next := <-done; next != 1 {
+		t.Fatalf("AwaitPhase = %d", next)
+	}
+	if cur, err := p.AwaitPhase(context.Background(), 0); cur != 1 || err != nil { // 已经结束的阶段立即返回
+		t.Fatalf("AwaitPhase past = %d, %v", cur, err)
+	}
+
+	// 等待超时的参与者依然算作到达
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.ArriveAndAwait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ArriveAndAwait = %v", err)
+	}
+	if p.Arrived() != 1 {
+		t.Fatalf("arrived = %d", p.Arrived())
+	}
+}
+
+func TestOnAdvance(t *testing.T) {
+	var seen []int
+	p := New(3, WithOnAdvance(func(phase, parties int) bool {
+		seen = append(seen, parties)
+		return phase >= 2 // 完成 3 个阶段后终止
+	}))
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			for {
+				if _, err := p.ArriveAndAwait(context.Background()); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; !errors.Is(err, ErrTerminated) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	if p.Phase() != 2 || len(seen) != 3 {
+		t.Fatalf("phase = %d, onAdvance calls = %v", p.Phase(), seen)
+	}
+
+	// ForceTermination 唤醒所有的等待者
+	q := New(2)
+	go q.ForceTermination()
+	if _, err := q.ArriveAndAwait(context.Background()); !errors.Is(err, ErrTerminated) {
+		t.Fatalf("after ForceTermination = %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// ConnConfig ConnPool 的配置，只有 Dial 是必须的，限制都是针对每个地址的
+type ConnConfig struct {
+	// Dial 建立到 addr 的连接
+	Dial func(ctx context.Context, addr string) (net.Conn, error)
+
+	MaxIdle     int
+	MaxOpen     int
+	MaxLifetime time.Duration
+	IdleTimeout time.Duration
+
+	// ProbeTimeout 不能做非阻塞读的连接（非 unix 系统，或者 TLS 等不是 syscall.Conn 的连接）
+	// 借出前探测连接是否断开的读超时，默认为 1ms
+	ProbeTimeout time.Duration
+	// Clock 计算寿命使用的时钟，默认为 clock.Real；探测的截止时间交给内核，总是使用真实时间
+	Clock clock.Clock
+}
+
+// ConnPool net.Conn 的连接池，每个地址一个 Pool，类似 fatih/pool，但是：
+//
+//	空闲的连接借出前探测，对端已经关闭、或者有未读取的数据的连接被关闭，Get 换一个
+//	unix 上对 socket 做一次非阻塞读，不会阻塞；其他连接用很短的读超时；新建立的连接不探测
+//	归还时不探测，Close 不会阻塞
+//	Close 等待借出的连接全部归还之后再关闭，ctx 结束时不再等待
+type ConnPool struct {
+	cfg ConnConfig
+
+	mu      sync.Mutex
+	pools   map[string]*Pool[net.Conn]
+	inUse   int
+	closed  bool
+	drained chan struct{} // Close 之后 inUse 减到 0 时关闭
+}
+
+// NewConnPool 创建连接池，连接在第一次 Get 时才建立
+func NewConnPool(cfg ConnConfig) (*ConnPool, error) {
+	if cfg.Dial == nil {
+		return nil, ErrInvalidConfig
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = time.Millisecond
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
+	return &ConnPool{cfg: cfg, pools: make(map[string]*Pool[net.Conn])}, nil
+}
+
+// Get 借出一个到 addr 的连接，使用完调用 PoolConn.Close 归还
+func (p *ConnPool) Get(ctx context.Context, addr string) (*PoolConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClosed
+	}
+	sub, ok := p.pools[addr]
+	if !ok {
+		var err error
+		sub, err = New(Config[net.Conn]{
+			New:         func(ctx context.Context) (net.Conn, error) { return p.cfg.Dial(ctx, addr) },
+			Destroy:     func(c net.Conn) { c.Close() },
+			Validate:    p.probe,
+			MaxIdle:     p.cfg.MaxIdle,
+			MaxOpen:     p.cfg.MaxOpen,
+			MaxLifetime: p.cfg.MaxLifetime,
+			IdleTimeout: p.cfg.IdleTimeout,
+			Clock:       p.cfg.Clock,
+		})
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.pools[addr] = sub
+	}
+	p.inUse++ // 等待中的 Get 也算，Close 等它结束
+	p.mu.Unlock()
+
+	r, err := sub.Get(ctx)
+	if err != nil {
+		p.done()
+		return nil, err
+	}
+	return &PoolConn{Conn: r.Value(), p: p, r: r}, nil
+}
+
+// probe 借出空闲连接前探测，已经断开时返回 errBroken
+func (p *ConnPool) probe(c net.Conn) error {
+	if broken(c, p.cfg.ProbeTimeout) {
+		return errBroken
+	}
+	return nil
+}
+
+var errBroken = errors.New("pool: connection broken")
+
+// done 一个借出的连接归还了
+func (p *ConnPool) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inUse--
+	if p.closed && p.inUse == 0 {
+		close(p.drained)
+	}
+}
+
+// Stats 每个地址的统计数据
+func (p *ConnPool) Stats() map[string]Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]Stats, len(p.pools))
+	for addr, sub := range p.pools {
+		stats[addr] = sub.Stats()
+	}
+	return stats
+}
+
+// Close 不再借出连接，等待借出的连接全部归还，然后关闭所有连接
+// ctx 结束时不再等待，返回 ctx.Err()，之后归还的连接直接关闭
+func (p *ConnPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	p.drained = make(chan struct{})
+	if p.inUse == 0 {
+		close(p.drained)
+	}
+	p.mu.Unlock()
+
+	var err error
+	select {
+	case <-p.drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	p.mu.Lock()
+	pools := p.pools
+	p.mu.Unlock()
+	for _, sub := range pools {
+		sub.Close()
+	}
+	return err
+}
+
+// PoolConn 从 ConnPool 借出的连接，Close 把它放回池中而不是关闭
+type PoolConn struct {
+	net.Conn
+	p *ConnPool
+	r *Resource[net.Conn]
+
+	mu       sync.Mutex
+	unusable bool
+	closed   bool
+}
+
+// MarkUnusable 标记连接已经不能使用（如协议出错），Close 时真正关闭它
+func (c *PoolConn) MarkUnusable() {
+	c.mu.Lock()
+	c.unusable = true
+	c.mu.Unlock()
+}
+
+// Close 归还连接；连接被标记为不能使用时关闭它，是否已经断开在下一次借出前探测
+func (c *PoolConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return net.ErrClosed
+	}
+	c.closed = true
+	unusable := c.unusable
+	c.mu.Unlock()
+
+	if unusable {
+		c.r.Destroy()
+	} else {
+		c.r.Release()
+	}
+	c.p.done()
+	return nil
+}
+
+// broken 探测连接：读到 EOF、出错说明已经断开；
+// 读到数据说明有上一次使用没有读完的响应，连接的状态已经不对，也不能再使用
+// 不能做非阻塞读时用 timeout 的读超时，超时说明连接正常；截止时间不能是过去的时间，否则 Read 不会真的去读
+func broken(c net.Conn, timeout time.Duration) bool {
+	if b, ok := peek(c); ok {
+		return b
+	}
+	if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return true
+	}
+	var b [1]byte
+	_, err := c.Read(b[:])
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return c.SetReadDeadline(time.Time{}) != nil
+	}
+	return true
+}
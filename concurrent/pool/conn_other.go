@@ -0,0 +1,8 @@
+//go:build !unix
+
+package pool
+
+import "net"
+
+// peek 非 unix 系统不做非阻塞读，都用读超时探测
+func peek(net.Conn) (broken, ok bool) { return false, false }
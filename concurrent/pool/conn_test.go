@@ -0,0 +1,242 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// echoServer 本地的 echo 服务器，返回地址和关闭所有已接受的连接的函数
+func echoServer(t *testing.T) (string, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+			go func() {
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					c.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String(), func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+		conns = nil
+	}
+}
+
+// plainConn 隐藏 TCPConn 的 SyscallConn，探测时只能用读超时
+type plainConn struct{ net.Conn }
+
+// newTestConnPool 创建 MaxOpen 为 2 的连接池，opts 修改其他配置
+func newTestConnPool(t *testing.T, opts ...func(*ConnConfig)) *ConnPool {
+	t.Helper()
+	var d net.Dialer
+	cfg := ConnConfig{
+		Dial: func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		MaxOpen: 2,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	p, err := NewConnPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func echo(t *testing.T, c net.Conn, msg string) {
+	t.Helper()
+	if _, err := c.Write([]byte(msg + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil || line != msg+"\n" {
+		t.Fatalf("echo = %q, %v", line, err)
+	}
+}
+
+func TestConnPoolReuse(t *testing.T) {
+	addr1, _ := echoServer(t)
+	addr2, _ := echoServer(t)
+	p := newTestConnPool(t)
+	defer p.Close(context.Background())
+
+	c, err := p.Get(context.Background(), addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, c, "hello")
+	raw := c.Conn
+	c.Close()
+	if err := c.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("second Close = %v", err)
+	}
+
+	c, _ = p.Get(context.Background(), addr1)
+	if c.Conn != raw {
+		t.Fatal("connection not reused")
+	}
+	echo(t, c, "again")
+	c.Close()
+
+	c, _ = p.Get(context.Background(), addr2) // 每个地址一个池
+	echo(t, c, "other")
+	c.Close()
+
+	stats := p.Stats()
+	if len(stats) != 2 || stats[addr1].Created != 1 || stats[addr1].Idle != 1 || stats[addr2].Idle != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestConnPoolBroken(t *testing.T) {
+	// 两种探测方式都与 Config.Clock 无关
+	fake := func(cfg *ConnConfig) { cfg.Clock = clock.NewFake(time.Unix(0, 0)) }
+	t.Run("peek", func(t *testing.T) { testConnPoolBroken(t, newTestConnPool(t, fake)) })
+	t.Run("deadline", func(t *testing.T) {
+		testConnPoolBroken(t, newTestConnPool(t, fake, func(cfg *ConnConfig) {
+			dial := cfg.Dial
+			cfg.Dial = func(ctx context.Context, addr string) (net.Conn, error) {
+				c, err := dial(ctx, addr)
+				if err != nil {
+					return nil, err
+				}
+				return &plainConn{c}, nil
+			}
+		}))
+	})
+}
+
+func testConnPoolBroken(t *testing.T, p *ConnPool) {
+	addr, closeAll := echoServer(t)
+	defer p.Close(context.Background())
+
+	// 对端关闭的连接在借出前被探测出来
+	c, _ := p.Get(context.Background(), addr)
+	echo(t, c, "hello")
+	closeAll()
+	time.Sleep(10 * time.Millisecond) // 等 FIN 到达
+	raw := c.Conn
+	c.Close() // 归还时不探测
+	c, _ = p.Get(context.Background(), addr)
+	if c.Conn == raw {
+		t.Fatal("broken connection reused")
+	}
+	if s := p.Stats()[addr]; s.Invalid != 1 || s.Destroyed != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// 有未读取的响应的连接也不能再使用
+	raw = c.Conn
+	c.Write([]byte("unread\n"))
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+	c, _ = p.Get(context.Background(), addr)
+	if c.Conn == raw {
+		t.Fatal("connection with unread data reused")
+	}
+	if s := p.Stats()[addr]; s.Invalid != 2 || s.Destroyed != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+	c.Close()
+
+	// MarkUnusable 的连接被关闭
+	c, _ = p.Get(context.Background(), addr)
+	echo(t, c, "hello")
+	c.MarkUnusable()
+	raw = c.Conn
+	c.Close()
+	if _, err := raw.Write([]byte("x")); err == nil {
+		t.Fatal("unusable connection not closed")
+	}
+	if s := p.Stats()[addr]; s.Idle != 0 || s.Destroyed != 3 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestConnPoolCloseDrains(t *testing.T) {
+	addr, _ := echoServer(t)
+	p := newTestConnPool(t)
+
+	c, _ := p.Get(context.Background(), addr)
+	idle, _ := p.Get(context.Background(), addr)
+	idle.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	closed := make(chan error)
+	go func() { closed <- p.Close(context.Background()) }()
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := p.Get(ctx, addr); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after Close = %v", err)
+	}
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a connection was borrowed")
+	default:
+	}
+	echo(t, c, "still usable while draining")
+	raw := c.Conn
+	c.Close()
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Write([]byte("x")); err == nil {
+		t.Fatal("connection not closed after drain")
+	}
+	if s := p.Stats()[addr]; s.Open != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestConnPoolCloseTimeout(t *testing.T) {
+	addr, _ := echoServer(t)
+	p := newTestConnPool(t)
+	c, _ := p.Get(context.Background(), addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close = %v", err)
+	}
+	raw := c.Conn
+	c.Close() // 超时之后归还的连接直接关闭
+	if _, err := raw.Write([]byte("x")); err == nil {
+		t.Fatal("connection returned after Close not closed")
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package pool
+
+import (
+	"net"
+	"syscall"
+)
+
+// peek 对连接的 socket 做一次非阻塞读：EAGAIN 说明连接正常；读到 EOF、数据或者出错说明不能再使用
+// 连接不是 syscall.Conn（如 TLS、net.Pipe）时 ok 为 false，需要用读超时探测
+func peek(c net.Conn) (broken, ok bool) {
+	sc, isSys := c.(syscall.Conn)
+	if !isSys {
+		return false, false
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+	var (
+		b    [1]byte
+		rerr error
+	)
+	err = rc.Read(func(fd uintptr) bool {
+		_, rerr = syscall.Read(int(fd), b[:])
+		return true // 不等待 fd 可读
+	})
+	if err != nil {
+		return true, true
+	}
+	return rerr != syscall.EAGAIN, true
+}
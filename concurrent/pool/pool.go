@@ -0,0 +1,474 @@
+// Package pool 通用的资源池，用于池化 TCP 连接、解析器等创建代价高、需要显式销毁的对象
+//
+// sync.Pool 中的对象会被垃圾回收掉，不适合长连接；begin/02.concurrent 中的 ObjPool 是固定大小的 channel，
+// 不能按需创建、不能检查健康状况，ReleaseObj 溢出时只能返回错误。Pool 提供：
+//
+//	Config.New、Config.Destroy        创建、销毁资源
+//	Config.MinIdle、MaxIdle、MaxOpen  空闲资源的下限和上限，资源总数的上限，达到上限时 Get 排队等待
+//	Config.MaxLifetime、IdleTimeout   资源从创建起的最长寿命、最长空闲时间，超过的在借出前或后台维护时销毁
+//	Config.Validate                   借出空闲资源前检查，失败的资源被销毁，Get 换一个
+//	Config.MaxWait                    Get 排队等待的最长时间，超过时返回 ErrWaitTimeout
+//
+// Get 返回 *Resource，使用完调用 Release 归还；资源已经损坏（如连接断开）时调用 Destroy
+// 等待的 Get 按 FIFO 的顺序获取资源，归还的资源直接交给队首的等待者
+package pool
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+var (
+	// ErrClosed 资源池已经关闭
+	ErrClosed = errors.New("pool: closed")
+	// ErrInvalidConfig 配置不合法
+	ErrInvalidConfig = errors.New("pool: invalid config")
+	// ErrWaitTimeout Get 排队等待超过了 MaxWait
+	ErrWaitTimeout = errors.New("pool: wait timeout")
+)
+
+// Config 资源池的配置，只有 New 是必须的
+type Config[T any] struct {
+	// New 创建资源，ctx 为 Get 的 ctx，后台补充空闲资源时为 context.Background()
+	New func(ctx context.Context) (T, error)
+	// Destroy 销毁资源，如关闭连接，可以为 nil
+	Destroy func(T)
+	// Validate 借出空闲资源前检查，返回错误时销毁资源，可以为 nil
+	// 刚创建的资源不检查，所以 Get 最多把空闲资源检查一遍，不会因为 Validate 一直失败而不停地创建
+	Validate func(T) error
+
+	MinIdle int // 后台维护时补充到的空闲资源数，New 时先创建这么多
+	MaxIdle int // 空闲资源的上限，超过时归还的资源被销毁，<= 0 表示不限制
+	MaxOpen int // 资源总数（包括借出的、空闲的和正在创建的）的上限，<= 0 表示不限制
+
+	MaxLifetime time.Duration // 资源从创建起的最长寿命，<= 0 表示不限制
+	IdleTimeout time.Duration // 资源的最长空闲时间，<= 0 表示不限制
+	MaxWait     time.Duration // Get 排队等待的最长时间，<= 0 表示只受 ctx 限制
+
+	// MaintainInterval 后台维护的周期：销毁过期的空闲资源、补充到 MinIdle
+	// 只在设置了 MinIdle、MaxLifetime 或 IdleTimeout 时启动，默认为 1s
+	MaintainInterval time.Duration
+	// Clock 计算寿命、维护周期和 MaxWait 使用的时钟，默认为 clock.Real
+	Clock clock.Clock
+}
+
+// Stats 资源池的统计数据
+type Stats struct {
+	MaxOpen int
+	Open    int // 资源总数，包括正在创建的
+	InUse   int // 借出的资源数
+	Idle    int // 空闲的资源数
+
+	WaitCount    int64         // 等待过的 Get 的次数
+	WaitDuration time.Duration // Get 等待的总时间
+
+	Created   int64 // 创建的资源数
+	Destroyed int64 // 销毁的资源数
+	Expired   int64 // 超过 MaxLifetime 或 IdleTimeout 被销毁的资源数
+	Invalid   int64 // Validate 失败被销毁的资源数
+}
+
+// Resource 从资源池借出的资源
+type Resource[T any] struct {
+	p         *Pool[T]
+	value     T
+	createdAt time.Time
+	idleSince time.Time
+	inUse     bool
+}
+
+// Value 资源的值，归还之后不能再使用
+func (r *Resource[T]) Value() T { return r.value }
+
+// CreatedAt 资源创建的时间
+func (r *Resource[T]) CreatedAt() time.Time { return r.createdAt }
+
+// Release 归还资源
+func (r *Resource[T]) Release() { r.p.release(r, false) }
+
+// Destroy 资源已经不能使用，销毁它而不是归还
+func (r *Resource[T]) Destroy() { r.p.release(r, true) }
+
+// grant 交给等待者的结果：r 为 nil 且 err 为 nil 时，表示为等待者预留了一个名额，由它自己创建
+type grant[T any] struct {
+	r   *Resource[T]
+	err error
+}
+
+// Pool 资源池，使用 New 创建
+type Pool[T any] struct {
+	cfg   Config[T]
+	clock clock.Clock
+
+	mu      sync.Mutex
+	idle    []*Resource[T] // 栈顶是最近归还的
+	open    int
+	inUse   int
+	waiters list.List // chan grant[T]，FIFO
+	closed  bool
+	stats   Stats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New 创建资源池，并先创建 MinIdle 个资源
+func New[T any](cfg Config[T]) (*Pool[T], error) {
+	if cfg.New == nil || cfg.MinIdle < 0 ||
+		(cfg.MaxOpen > 0 && cfg.MinIdle > cfg.MaxOpen) ||
+		(cfg.MaxIdle > 0 && cfg.MinIdle > cfg.MaxIdle) {
+		return nil, ErrInvalidConfig
+	}
+	if cfg.MaintainInterval <= 0 {
+		cfg.MaintainInterval = time.Second
+	}
+	p := &Pool[T]{cfg: cfg, clock: cfg.Clock, stop: make(chan struct{}), done: make(chan struct{})}
+	if p.clock == nil {
+		p.clock = clock.Real
+	}
+	for i := 0; i < cfg.MinIdle; i++ {
+		r, err := p.create(context.Background())
+		if err != nil {
+			for _, r := range p.idle {
+				p.destroy(r)
+			}
+			return nil, err
+		}
+		p.open++
+		p.idle = append(p.idle, r)
+	}
+	if cfg.MinIdle > 0 || cfg.MaxLifetime > 0 || cfg.IdleTimeout > 0 {
+		go p.maintain()
+	} else {
+		close(p.done)
+	}
+	return p, nil
+}
+
+// Get 借出一个资源，没有空闲资源且达到 MaxOpen 时等待，直到有资源归还、等待超过 MaxWait 或 ctx 结束
+func (p *Pool[T]) Get(ctx context.Context) (*Resource[T], error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, created, err := p.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if created || p.check(r) {
+			return r, nil
+		}
+		// 检查失败，已经销毁，重新获取
+	}
+}
+
+// get 返回空闲的、新创建的或等待到的资源，created 表示是新创建的；空闲的资源还没有经过 check
+func (p *Pool[T]) get(ctx context.Context) (r *Resource[T], created bool, err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, false, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		r := p.idle[n-1]
+		p.idle[n-1] = nil
+		p.idle = p.idle[:n-1]
+		r.inUse = true
+		p.inUse++
+		p.mu.Unlock()
+		return r, false, nil
+	}
+	if p.cfg.MaxOpen <= 0 || p.open < p.cfg.MaxOpen {
+		p.open++
+		p.mu.Unlock()
+		r, err := p.createInUse(ctx)
+		return r, true, err
+	}
+
+	// 达到上限，排队等待
+	if err := ctx.Err(); err != nil {
+		p.mu.Unlock()
+		return nil, false, err
+	}
+	ch := make(chan grant[T], 1)
+	elem := p.waiters.PushBack(ch)
+	p.stats.WaitCount++
+	start := p.clock.Now()
+	p.mu.Unlock()
+
+	var timeout <-chan time.Time // nil 时永远不会超时
+	if p.cfg.MaxWait > 0 {
+		timer := p.clock.NewTimer(p.cfg.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	select {
+	case g := <-ch:
+		p.waited(start)
+		if g.err != nil {
+			return nil, false, g.err
+		}
+		if g.r != nil {
+			return g.r, false, nil
+		}
+		r, err := p.createInUse(ctx) // 预留了名额
+		return r, true, err
+	case <-timeout:
+		p.abandon(elem, ch, start)
+		return nil, false, ErrWaitTimeout
+	case <-ctx.Done():
+		p.abandon(elem, ch, start)
+		return nil, false, ctx.Err()
+	}
+}
+
+// abandon 等待者超时或取消时离开队列
+func (p *Pool[T]) abandon(elem *list.Element, ch chan grant[T], start time.Time) {
+	p.mu.Lock()
+	p.waiters.Remove(elem) // 已经被取出时什么也不做
+	p.mu.Unlock()
+	p.waited(start)
+	select {
+	case g := <-ch: // 离开之前已经得到了资源或名额，还回去
+		if g.r != nil {
+			p.release(g.r, false)
+		} else if g.err == nil {
+			p.mu.Lock()
+			p.open--
+			p.handoffSlot()
+			p.mu.Unlock()
+		}
+	default:
+	}
+}
+
+func (p *Pool[T]) waited(start time.Time) {
+	d := p.clock.Since(start)
+	p.mu.Lock()
+	p.stats.WaitDuration += d
+	p.mu.Unlock()
+}
+
+// createInUse 使用已经预留的名额创建资源并借出，失败时释放名额
+func (p *Pool[T]) createInUse(ctx context.Context) (*Resource[T], error) {
+	r, err := p.create(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.open--
+		p.handoffSlot()
+		return nil, err
+	}
+	r.inUse = true
+	p.inUse++
+	return r, nil
+}
+
+// create 调用 New 创建资源，调用者已经增加了 p.open
+func (p *Pool[T]) create(ctx context.Context) (*Resource[T], error) {
+	v, err := p.cfg.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := p.clock.Now()
+	p.mu.Lock()
+	p.stats.Created++
+	p.mu.Unlock()
+	return &Resource[T]{p: p, value: v, createdAt: now, idleSince: now}, nil
+}
+
+// check 借出空闲资源前检查它是否过期、是否可用，不可用时销毁并返回 false
+func (p *Pool[T]) check(r *Resource[T]) bool {
+	if p.expired(r, p.clock.Now()) {
+		p.mu.Lock()
+		p.stats.Expired++
+		p.mu.Unlock()
+		p.release(r, true)
+		return false
+	}
+	if p.cfg.Validate != nil && p.cfg.Validate(r.value) != nil {
+		p.mu.Lock()
+		p.stats.Invalid++
+		p.mu.Unlock()
+		p.release(r, true)
+		return false
+	}
+	return true
+}
+
+// expired 资源是否超过了最长寿命或最长空闲时间
+func (p *Pool[T]) expired(r *Resource[T], now time.Time) bool {
+	return (p.cfg.MaxLifetime > 0 && now.Sub(r.createdAt) >= p.cfg.MaxLifetime) ||
+		(p.cfg.IdleTimeout > 0 && now.Sub(r.idleSince) >= p.cfg.IdleTimeout)
+}
+
+// release 归还或销毁借出的资源
+func (p *Pool[T]) release(r *Resource[T], destroy bool) {
+	now := p.clock.Now()
+	p.mu.Lock()
+	if !r.inUse {
+		p.mu.Unlock()
+		panic("pool: release of idle resource")
+	}
+	r.inUse = false
+	p.inUse--
+	if !destroy && !p.closed && p.cfg.MaxLifetime > 0 && now.Sub(r.createdAt) >= p.cfg.MaxLifetime {
+		p.stats.Expired++
+		destroy = true
+	}
+	if destroy || p.closed {
+		p.open--
+		p.handoffSlot()
+		p.mu.Unlock()
+		p.destroy(r)
+		return
+	}
+	r.idleSince = now
+	if elem := p.waiters.Front(); elem != nil { // 直接交给等待者
+		p.waiters.Remove(elem)
+		r.inUse = true
+		p.inUse++
+		elem.Value.(chan grant[T]) <- grant[T]{r: r}
+		p.mu.Unlock()
+		return
+	}
+	if p.cfg.MaxIdle > 0 && len(p.idle) >= p.cfg.MaxIdle {
+		p.open--
+		p.mu.Unlock()
+		p.destroy(r)
+		return
+	}
+	p.idle = append(p.idle, r)
+	p.mu.Unlock()
+}
+
+// handoffSlot 资源总数减少之后，把空出来的名额交给队首的等待者，需要持有 p.mu
+func (p *Pool[T]) handoffSlot() {
+	if elem := p.waiters.Front(); elem != nil {
+		p.waiters.Remove(elem)
+		p.open++
+		elem.Value.(chan grant[T]) <- grant[T]{}
+	}
+}
+
+// destroy 调用 Destroy 销毁资源，调用者已经减少了 p.open，不能持有 p.mu
+func (p *Pool[T]) destroy(r *Resource[T]) {
+	if p.cfg.Destroy != nil {
+		p.cfg.Destroy(r.value)
+	}
+	p.mu.Lock()
+	p.stats.Destroyed++
+	p.mu.Unlock()
+}
+
+// maintain 周期性地销毁过期的空闲资源、补充到 MinIdle
+func (p *Pool[T]) maintain() {
+	defer close(p.done)
+	ticker := p.clock.NewTicker(p.cfg.MaintainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C():
+			p.evict()
+			p.fill()
+		}
+	}
+}
+
+// evict 销毁过期的空闲资源
+func (p *Pool[T]) evict() {
+	now := p.clock.Now()
+	var expired []*Resource[T]
+	p.mu.Lock()
+	idle := p.idle[:0]
+	for _, r := range p.idle {
+		if p.expired(r, now) {
+			expired = append(expired, r)
+		} else {
+			idle = append(idle, r)
+		}
+	}
+	for i := len(idle); i < len(p.idle); i++ {
+		p.idle[i] = nil
+	}
+	p.idle = idle
+	p.open -= len(expired)
+	p.stats.Expired += int64(len(expired))
+	for range expired {
+		p.handoffSlot()
+	}
+	p.mu.Unlock()
+	for _, r := range expired {
+		p.destroy(r)
+	}
+}
+
+// fill 补充空闲资源到 MinIdle，创建失败时等下一个周期再试
+func (p *Pool[T]) fill() {
+	for {
+		p.mu.Lock()
+		if p.closed || len(p.idle) >= p.cfg.MinIdle ||
+			(p.cfg.MaxOpen > 0 && p.open >= p.cfg.MaxOpen) {
+			p.mu.Unlock()
+			return
+		}
+		p.open++
+		p.mu.Unlock()
+
+		r, err := p.create(context.Background())
+		p.mu.Lock()
+		if err != nil {
+			p.open--
+			p.handoffSlot()
+			p.mu.Unlock()
+			return
+		}
+		r.inUse = true // 通过 release 放入空闲列表，有等待者时直接交给它
+		p.inUse++
+		p.mu.Unlock()
+		p.release(r, false)
+	}
+}
+
+// Stats 返回当前的统计数据
+func (p *Pool[T]) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.MaxOpen = p.cfg.MaxOpen
+	s.Open = p.open
+	s.InUse = p.inUse
+	s.Idle = len(p.idle)
+	return s
+}
+
+// Close 关闭资源池：销毁空闲的资源，等待的 Get 返回 ErrClosed，借出的资源在归还时销毁
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	for elem := p.waiters.Front(); elem != nil; elem = p.waiters.Front() {
+		p.waiters.Remove(elem)
+		elem.Value.(chan grant[T]) <- grant[T]{err: ErrClosed}
+	}
+	p.mu.Unlock()
+
+	close(p.stop)
+	<-p.done
+	for _, r := range idle {
+		p.destroy(r)
+	}
+}
@@ -0,0 +1,341 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+type conn struct {
+	id     int
+	broken bool
+	closed atomic.Bool
+}
+
+// factory 返回创建 conn 的 New 和已经创建的 conn
+func factory() (func(context.Context) (*conn, error), func() []*conn) {
+	var (
+		mu    sync.Mutex
+		conns []*conn
+	)
+	return func(context.Context) (*conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c := &conn{id: len(conns)}
+			conns = append(conns, c)
+			return c, nil
+		}, func() []*conn {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]*conn(nil), conns...)
+		}
+}
+
+func destroy(c *conn) { c.closed.Store(true) }
+
+func TestGetRelease(t *testing.T) {
+	newConn, _ := factory()
+	p, err := New(Config[*conn]{New: newConn, Destroy: destroy, MaxIdle: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	b, _ := p.Get(context.Background())
+	if a.Value() == b.Value() {
+		t.Fatal("same resource borrowed twice")
+	}
+	a.Release()
+	b.Release() // 超过 MaxIdle，被销毁
+	if !b.Value().closed.Load() || a.Value().closed.Load() {
+		t.Fatal("MaxIdle not enforced")
+	}
+	c, _ := p.Get(context.Background())
+	if c.Value() != a.Value() {
+		t.Fatal("idle resource not reused")
+	}
+	c.Release()
+
+	s := p.Stats()
+	if s.Open != 1 || s.Idle != 1 || s.InUse != 0 || s.Created != 2 || s.Destroyed != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("double release did not panic")
+			}
+		}()
+		c.Release()
+	}()
+}
+
+func TestMaxOpen(t *testing.T) {
+	newConn, _ := factory()
+	c := clock.NewFake(time.Unix(0, 0))
+	p, _ := New(Config[*conn]{New: newConn, MaxOpen: 1, MaxWait: time.Second, Clock: c})
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+	c.BlockUntil(1) // 已经排队，开始计时
+	c.Advance(time.Second)
+	if err := <-errc; !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("Get = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get with canceled ctx = %v", err)
+	}
+
+	got := make(chan *Resource[*conn])
+	go func() {
+		r, _ := p.Get(context.Background())
+		got <- r
+	}()
+	c.BlockUntil(1)
+	a.Release() // 直接交给等待者
+	r := <-got
+	if r.Value() != a.Value() {
+		t.Fatal("released resource not handed to waiter")
+	}
+	r.Release()
+	if s := p.Stats(); s.Open != 1 || s.Idle != 1 || s.WaitCount != 2 || s.WaitDuration != time.Second {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestDestroyHandsOffSlot(t *testing.T) {
+	newConn, _ := factory()
+	p, _ := New(Config[*conn]{New: newConn, Destroy: destroy, MaxOpen: 1})
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	got := make(chan *Resource[*conn])
+	go func() {
+		r, _ := p.Get(context.Background())
+		got <- r
+	}()
+	for p.Stats().WaitCount < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	a.Value().broken = true
+	a.Destroy()
+	r := <-got
+	if r.Value() == a.Value() || !a.Value().closed.Load() {
+		t.Fatal("waiter did not get a new resource")
+	}
+	if s := p.Stats(); s.Open != 1 || s.InUse != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+	r.Release()
+}
+
+func TestValidate(t *testing.T) {
+	newConn, _ := factory()
+	p, _ := New(Config[*conn]{
+		New:     newConn,
+		Destroy: destroy,
+		Validate: func(c *conn) error {
+			if c.broken {
+				return errors.New("broken")
+			}
+			return nil
+		},
+	})
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	a.Value().broken = true // 使用时没有发现，归还之后在借出前检查出来
+	a.Release()
+	b, _ := p.Get(context.Background())
+	if b.Value() == a.Value() || !a.Value().closed.Load() {
+		t.Fatal("invalid resource borrowed")
+	}
+	if s := p.Stats(); s.Invalid != 1 || s.Open != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+	b.Release()
+
+	// Validate 一直失败时只检查空闲资源，新创建的直接借出
+	newConn, _ = factory()
+	q, _ := New(Config[*conn]{New: newConn, Validate: func(*conn) error { return errors.New("broken") }})
+	defer q.Close()
+	var rs []*Resource[*conn]
+	for i := 0; i < 3; i++ {
+		r, _ := q.Get(context.Background())
+		rs = append(rs, r)
+	}
+	for _, r := range rs {
+		r.Release()
+	}
+	r, err := q.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := q.Stats(); s.Invalid != 3 || s.Created != 4 {
+		t.Fatalf("stats = %+v", s)
+	}
+	r.Release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get with canceled ctx = %v", err)
+	}
+}
+
+func TestLifetimeAndIdleTimeout(t *testing.T) {
+	c := clock.NewFake(time.Now())
+	newConn, conns := factory()
+	p, _ := New(Config[*conn]{
+		New:              newConn,
+		Destroy:          destroy,
+		MinIdle:          1,
+		MaxLifetime:      time.Hour,
+		IdleTimeout:      time.Minute,
+		MaintainInterval: 10 * time.Second,
+		Clock:            c,
+	})
+	defer p.Close()
+	c.BlockUntil(1) // 后台维护的 ticker
+
+	// 空闲超时，借出前检查出来
+	c.Advance(59 * time.Second)
+	first := conns()[0]
+	r, _ := p.Get(context.Background())
+	if r.Value() != first {
+		t.Fatal("fresh idle resource not reused")
+	}
+	r.Release()
+	c.Advance(2 * time.Minute) // 后台维护销毁空闲超时的，并补充到 MinIdle
+	waitFor(t, func() bool { return first.closed.Load() && p.Stats().Idle == 1 })
+
+	// 超过最长寿命，归还时销毁
+	r, _ = p.Get(context.Background())
+	for i := 0; i < 7; i++ {
+		c.Advance(10 * time.Minute)
+	}
+	r.Release()
+	if !r.Value().closed.Load() {
+		t.Fatal("resource past MaxLifetime not destroyed on release")
+	}
+	if s := p.Stats(); s.Expired < 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestClose(t *testing.T) {
+	newConn, _ := factory()
+	p, _ := New(Config[*conn]{New: newConn, Destroy: destroy, MaxOpen: 2, MinIdle: 1})
+	a, _ := p.Get(context.Background())
+	b, _ := p.Get(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+	for p.Stats().WaitCount < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	a.Release()
+	<-errc // a 交给了等待者
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+	for p.Stats().WaitCount < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	p.Close()
+	if err := <-errc; !errors.Is(err, ErrClosed) {
+		t.Fatalf("waiting Get = %v", err)
+	}
+	if _, err := p.Get(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after Close = %v", err)
+	}
+	b.Release() // 关闭之后归还的被销毁
+	if !b.Value().closed.Load() {
+		t.Fatal("resource released after Close not destroyed")
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	newConn, _ := factory()
+	for _, cfg := range []Config[*conn]{
+		{},
+		{New: newConn, MinIdle: 3, MaxOpen: 2},
+		{New: newConn, MinIdle: 3, MaxIdle: 2},
+	} {
+		if _, err := New(cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("New(%+v) = %v", cfg, err)
+		}
+	}
+	errBoom := errors.New("boom")
+	if _, err := New(Config[int]{New: func(context.Context) (int, error) { return 0, errBoom }, MinIdle: 1}); !errors.Is(err, errBoom) {
+		t.Errorf("New = %v", err)
+	}
+}
+
+func TestStress(t *testing.T) {
+	newConn, _ := factory()
+	var inUse atomic.Int32
+	p, _ := New(Config[*conn]{New: newConn, Destroy: destroy, MaxOpen: 4, MaxIdle: 2})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(j%3)*time.Microsecond)
+				r, err := p.Get(ctx)
+				cancel()
+				if err != nil {
+					continue
+				}
+				if n := inUse.Add(1); n > 4 {
+					t.Errorf("%d resources in use", n)
+				}
+				if r.Value().closed.Load() {
+					t.Error("destroyed resource borrowed")
+				}
+				inUse.Add(-1)
+				if (i+j)%5 == 0 {
+					r.Destroy()
+				} else {
+					r.Release()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s := p.Stats(); s.InUse != 0 || s.Open != s.Idle || s.Open > 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+	p.Close()
+	if s := p.Stats(); s.Open != 0 || s.Created != s.Destroyed {
+		t.Fatalf("stats after Close = %+v", s)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,315 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"golang/concurrent/future"
+)
+
+var (
+	// ErrQueueFull 队列已满，RejectPolicy 为 Reject 时 Submit 返回
+	ErrQueueFull = errors.New("pool: queue full")
+	// ErrDropped 队列已满，RejectPolicy 为 Drop 时任务的结果
+	ErrDropped = errors.New("pool: task dropped")
+	// ErrStopped 已经停止，不再接受任务；StopNow 时队列中还没有执行的任务的结果
+	ErrStopped = errors.New("pool: stopped")
+)
+
+// RejectPolicy 队列已满时如何处理新提交的任务
+type RejectPolicy int
+
+const (
+	Block      RejectPolicy = iota // 阻塞到队列有空位或 ctx 结束
+	CallerRuns                     // 在调用 Submit 的 goroutine 中直接执行，自然地减慢提交的速度
+	Drop                           // 丢弃任务，Submit 不返回错误，任务的结果为 ErrDropped
+	Reject                         // Submit 返回 ErrQueueFull
+)
+
+// WorkerConfig WorkerPool 的配置
+type WorkerConfig struct {
+	Workers   int          // worker 的数量，至少为 1，可以用 Resize 调整
+	QueueSize int          // 等待执行的任务队列的容量，0 表示只有空闲的 worker 时才能提交
+	Policy    RejectPolicy // 队列已满时的策略，默认为 Block
+}
+
+// WorkerMetrics WorkerPool 的统计数据
+type WorkerMetrics struct {
+	Workers int // worker 的数量
+	Queued  int // 队列中等待执行的任务数
+	Running int // 正在执行的任务数
+	Blocked int // Block 时阻塞在已满的队列上的 Submit 数
+
+	Submitted  int64 // 接受的任务数，包括 CallerRuns 执行的
+	Completed  int64 // 执行完的任务数，包括失败的
+	Failed     int64 // 返回错误或 panic 的任务数
+	Panicked   int64 // panic 的任务数
+	CallerRuns int64 // 在调用者的 goroutine 中执行的任务数
+	Dropped    int64 // Drop 丢弃的任务数
+	Rejected   int64 // Reject 拒绝的任务数
+}
+
+// Result SubmitTo 发送的结果
+type Result[In, Out any] struct {
+	In  In
+	Out Out
+	Err error
+}
+
+type task[In, Out any] struct {
+	in   In
+	done func(Out, error)
+}
+
+// WorkerPool 固定数量的 worker 执行同一个函数，8.pool 中的 gammazero/workerpool、grpool、goworkers
+// 和 SemaWorkerPool 都只能提交 func()，结果要自己传递；WorkerPool 的任务有输入和输出：
+//
+//	Submit 返回 future.Future，SubmitTo 把结果发送到 channel
+//	队列有界，满了之后按 RejectPolicy 处理
+//	fn panic 时被恢复，任务的结果为 *future.PanicError，worker 继续执行后面的任务
+//	Resize 调整 worker 的数量，StopWait 执行完队列中的任务再停止，StopNow 取消正在执行的任务、丢弃队列中的任务
+type WorkerPool[In, Out any] struct {
+	fn     func(ctx context.Context, in In) (Out, error)
+	policy RejectPolicy
+	queue  chan task[In, Out]
+
+	ctx      context.Context // StopNow 时取消，传给 fn
+	cancel   context.CancelFunc
+	stopping chan struct{} // 开始停止时关闭，唤醒阻塞的 Submit
+	stopOnce sync.Once
+
+	mu      sync.RWMutex // Submit 持有读锁检查 stopped、不阻塞地发送，停止时持有写锁设置 stopped
+	stopped bool
+	senders sync.WaitGroup  // 在锁外阻塞发送的 Submit，停止时等它们离开之后才关闭 queue
+	quits   []chan struct{} // 每个 worker 一个，关闭时 worker 退出
+	wg      sync.WaitGroup
+
+	running, blocked                                                      atomic.Int64 // 正在执行的任务数、阻塞的 Submit 数
+	submitted, completed, failed, panicked, callerRuns, dropped, rejected atomic.Int64
+}
+
+// NewWorkerPool 创建 WorkerPool，fn 的 ctx 在 StopNow 时被取消
+func NewWorkerPool[In, Out any](fn func(ctx context.Context, in In) (Out, error), cfg WorkerConfig) (*WorkerPool[In, Out], error) {
+	if fn == nil || cfg.Workers < 1 || cfg.QueueSize < 0 || cfg.Policy < Block || cfg.Policy > Reject {
+		return nil, ErrInvalidConfig
+	}
+	p := &WorkerPool[In, Out]{
+		fn:       fn,
+		policy:   cfg.Policy,
+		queue:    make(chan task[In, Out], cfg.QueueSize),
+		stopping: make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.Resize(cfg.Workers)
+	return p, nil
+}
+
+// Submit 提交任务，返回任务的结果
+// 已经停止时返回 ErrStopped；Block 时 ctx 结束返回 ctx.Err()；Reject 时队列已满返回 ErrQueueFull
+func (p *WorkerPool[In, Out]) Submit(ctx context.Context, in In) (*future.Future[Out], error) {
+	promise := future.NewPromise[Out]()
+	err := p.submit(ctx, in, false, func(v Out, err error) {
+		if err != nil {
+			promise.Reject(err)
+		} else {
+			promise.Resolve(v)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promise.Future(), nil
+}
+
+// SubmitTo 提交任务，完成后把结果发送到 results
+// 结果在 worker 的 goroutine 中发送，results 没有人读取时 worker 会阻塞，调用者要保证一直读取
+func (p *WorkerPool[In, Out]) SubmitTo(ctx context.Context, in In, results chan<- Result[In, Out]) error {
+	return p.submit(ctx, in, true, func(v Out, err error) {
+		results <- Result[In, Out]{In: in, Out: v, Err: err}
+	})
+}
+
+// submit 提交任务，完成后调用 done；mayBlock 表示 done 可能阻塞，不能在调用者的 goroutine 中调用
+func (p *WorkerPool[In, Out]) submit(ctx context.Context, in In, mayBlock bool, done func(Out, error)) error {
+	t := task[In, Out]{in: in, done: done}
+	p.mu.RLock()
+	if p.stopped {
+		p.mu.RUnlock()
+		return ErrStopped
+	}
+	select {
+	case p.queue <- t:
+		p.mu.RUnlock()
+		p.submitted.Add(1)
+		return nil
+	default:
+	}
+
+	switch p.policy {
+	case CallerRuns:
+		p.mu.RUnlock()
+		p.submitted.Add(1)
+		p.callerRuns.Add(1)
+		p.run(t)
+		return nil
+	case Drop:
+		p.mu.RUnlock()
+		p.dropped.Add(1)
+		var zero Out
+		if mayBlock { // SubmitTo 的 results 可能由调用者读取，不能在调用者的 goroutine 中发送
+			go done(zero, ErrDropped)
+		} else {
+			done(zero, ErrDropped)
+		}
+		return nil
+	case Reject:
+		p.mu.RUnlock()
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+
+	// 阻塞发送时不能持有读锁，否则等待中的 Resize 会挡住 Metrics、Submit
+	// 在读锁内登记，stop 设置 stopped 之后不会再有新的发送者，等已经登记的离开之后才关闭 queue
+	p.senders.Add(1)
+	p.mu.RUnlock()
+	defer p.senders.Done()
+	p.blocked.Add(1)
+	defer p.blocked.Add(-1)
+	select {
+	case p.queue <- t:
+		p.submitted.Add(1)
+		return nil
+	case <-p.stopping:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 执行任务，把 panic 转换为 *future.PanicError
+func (p *WorkerPool[In, Out]) run(t task[In, Out]) {
+	var (
+		v   Out
+		err error
+	)
+	if err = p.ctx.Err(); err != nil { // StopNow 之后取出的任务不再执行
+		t.done(v, ErrStopped)
+		return
+	}
+	p.running.Add(1)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &future.PanicError{Value: r, Stack: debug.Stack()}
+				p.panicked.Add(1)
+			}
+		}()
+		v, err = p.fn(p.ctx, t.in)
+	}()
+	p.running.Add(-1)
+	p.completed.Add(1)
+	if err != nil {
+		p.failed.Add(1)
+	}
+	t.done(v, err)
+}
+
+func (p *WorkerPool[In, Out]) worker(quit <-chan struct{}) {
+	defer p.wg.Done()
+	for {
+		select { // 优先退出，Resize 缩小时空闲的 worker 不再取任务
+		case <-quit:
+			return
+		default:
+		}
+		select {
+		case t, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			p.run(t)
+		case <-quit:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// Resize 调整 worker 的数量，n 至少为 1；缩小时正在执行任务的 worker 执行完当前的任务再退出
+func (p *WorkerPool[In, Out]) Resize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	for len(p.quits) < n {
+		quit := make(chan struct{})
+		p.quits = append(p.quits, quit)
+		p.wg.Add(1)
+		go p.worker(quit)
+	}
+	for len(p.quits) > n {
+		last := len(p.quits) - 1
+		close(p.quits[last])
+		p.quits = p.quits[:last]
+	}
+}
+
+// StopWait 不再接受新的任务，等待队列中的任务全部执行完
+func (p *WorkerPool[In, Out]) StopWait() {
+	p.stop()
+	p.wg.Wait()
+}
+
+// StopNow 不再接受新的任务，取消正在执行的任务的 ctx，队列中还没有执行的任务的结果为 ErrStopped
+// 等待正在执行的任务返回
+func (p *WorkerPool[In, Out]) StopNow() {
+	p.cancel()
+	p.stop()
+	p.wg.Wait()
+	for t := range p.queue {
+		var zero Out
+		t.done(zero, ErrStopped)
+	}
+}
+
+func (p *WorkerPool[In, Out]) stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopping) // 唤醒阻塞的 Submit
+		p.mu.Lock()
+		p.stopped = true
+		p.mu.Unlock()
+		p.senders.Wait()
+		close(p.queue)
+	})
+}
+
+// Metrics 返回当前的统计数据
+func (p *WorkerPool[In, Out]) Metrics() WorkerMetrics {
+	p.mu.RLock()
+	workers := len(p.quits)
+	if p.stopped {
+		workers = 0
+	}
+	p.mu.RUnlock()
+	return WorkerMetrics{
+		Workers:    workers,
+		Queued:     len(p.queue),
+		Running:    int(p.running.Load()),
+		Blocked:    int(p.blocked.Load()),
+		Submitted:  p.submitted.Load(),
+		Completed:  p.completed.Load(),
+		Failed:     p.failed.Load(),
+		Panicked:   p.panicked.Load(),
+		CallerRuns: p.callerRuns.Load(),
+		Dropped:    p.dropped.Load(),
+		Rejected:   p.rejected.Load(),
+	}
+}
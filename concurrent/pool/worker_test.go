@@ -0,0 +1,288 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"golang/concurrent/future"
+)
+
+func square(_ context.Context, n int) (int, error) { return n * n, nil }
+
+func TestWorkerPoolSubmit(t *testing.T) {
+	p, err := NewWorkerPool(square, WorkerConfig{Workers: 4, QueueSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fs []*future.Future[int]
+	for i := 0; i < 100; i++ {
+		f, err := p.Submit(context.Background(), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs = append(fs, f)
+	}
+	for i, f := range fs {
+		if v, err := f.Get(context.Background()); err != nil || v != i*i {
+			t.Fatalf("task %d = %d, %v", i, v, err)
+		}
+	}
+
+	results := make(chan Result[int, int], 10)
+	for i := 0; i < 10; i++ {
+		if err := p.SubmitTo(context.Background(), i, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		r := <-results
+		if r.Err != nil || r.Out != r.In*r.In {
+			t.Fatalf("result = %+v", r)
+		}
+		sum += r.In
+	}
+	if sum != 45 {
+		t.Fatalf("sum = %d", sum)
+	}
+
+	p.StopWait()
+	if _, err := p.Submit(context.Background(), 1); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Submit after stop = %v", err)
+	}
+	if m := p.Metrics(); m.Submitted != 110 || m.Completed != 110 || m.Workers != 0 {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
+
+func TestWorkerPoolPanic(t *testing.T) {
+	errBoom := errors.New("boom")
+	p, _ := NewWorkerPool(func(_ context.Context, n int) (int, error) {
+		switch n {
+		case 1:
+			panic(errBoom)
+		case 2:
+			return 0, errors.New("failed")
+		}
+		return n, nil
+	}, WorkerConfig{Workers: 1})
+	defer p.StopWait()
+
+	f, _ := p.Submit(context.Background(), 1)
+	_, err := f.Get(context.Background())
+	var pe *future.PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v", err)
+	}
+	f, _ = p.Submit(context.Background(), 2)
+	f.Get(context.Background())
+	f, _ = p.Submit(context.Background(), 3) // panic 之后 worker 还在
+	if v, err := f.Get(context.Background()); err != nil || v != 3 {
+		t.Fatalf("after panic = %d, %v", v, err)
+	}
+	if m := p.Metrics(); m.Panicked != 1 || m.Failed != 2 || m.Completed != 3 {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
+
+// blockingPool 一个 worker、容量为 1 的队列，worker 阻塞到 release 关闭，队列已满
+func blockingPool(t *testing.T, policy RejectPolicy) (*WorkerPool[int, int], chan struct{}) {
+	t.Helper()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	p, _ := NewWorkerPool(func(ctx context.Context, n int) (int, error) {
+		if n < 0 {
+			return n, nil
+		}
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		return n, nil
+	}, WorkerConfig{Workers: 1, QueueSize: 1, Policy: policy})
+	p.Submit(context.Background(), 1)
+	<-started
+	p.Submit(context.Background(), 2) // 占满队列
+	return p, release
+}
+
+func TestWorkerPoolRejectPolicy(t *testing.T) {
+	t.Run("Block", func(t *testing.T) {
+		p, release := blockingPool(t, Block)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := p.Submit(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Submit = %v", err)
+		}
+		f := make(chan *future.Future[int])
+		go func() {
+			fut, _ := p.Submit(context.Background(), 3)
+			f <- fut
+		}()
+		for p.Metrics().Blocked == 0 {
+			runtime.Gosched()
+		}
+		p.Resize(2) // 阻塞的 Submit 不持有锁，不会挡住 Resize
+		close(release)
+		if v, err := (<-f).Get(context.Background()); err != nil || v != 3 {
+			t.Fatalf("blocked task = %d, %v", v, err)
+		}
+		p.StopWait()
+	})
+	t.Run("CallerRuns", func(t *testing.T) {
+		p, release := blockingPool(t, CallerRuns)
+		f, err := p.Submit(context.Background(), -1) // 在当前 goroutine 中执行
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-f.Done():
+		default:
+			t.Fatal("caller-runs task not completed synchronously")
+		}
+		close(release)
+		p.StopWait()
+		if m := p.Metrics(); m.CallerRuns != 1 || m.Completed != 3 {
+			t.Fatalf("metrics = %+v", m)
+		}
+	})
+	t.Run("Drop", func(t *testing.T) {
+		p, release := blockingPool(t, Drop)
+		f, err := p.Submit(context.Background(), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select { // 在 Submit 返回之前已经完成，不会为被丢弃的任务启动 goroutine
+		case <-f.Done():
+		default:
+			t.Fatal("dropped future not settled by Submit")
+		}
+		if _, err := f.Get(context.Background()); !errors.Is(err, ErrDropped) {
+			t.Fatalf("dropped task = %v", err)
+		}
+		close(release)
+		p.StopWait()
+		if m := p.Metrics(); m.Dropped != 1 || m.Submitted != 2 {
+			t.Fatalf("metrics = %+v", m)
+		}
+	})
+	t.Run("Reject", func(t *testing.T) {
+		p, release := blockingPool(t, Reject)
+		if _, err := p.Submit(context.Background(), 3); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("Submit = %v", err)
+		}
+		close(release)
+		p.StopWait()
+		if m := p.Metrics(); m.Rejected != 1 {
+			t.Fatalf("metrics = %+v", m)
+		}
+	})
+}
+
+func TestWorkerPoolStopNow(t *testing.T) {
+	p, _ := blockingPool(t, Block)
+	blocked := make(chan error)
+	go func() {
+		_, err := p.Submit(context.Background(), 4) // 阻塞在已满的队列上
+		blocked <- err
+	}()
+	for p.Metrics().Blocked == 0 {
+		runtime.Gosched()
+	}
+
+	p.StopNow()
+	if err := <-blocked; !errors.Is(err, ErrStopped) {
+		t.Fatalf("blocked Submit = %v", err)
+	}
+	if m := p.Metrics(); m.Queued != 0 || m.Running != 0 || m.Workers != 0 {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
+
+func TestWorkerPoolStopNowResults(t *testing.T) {
+	started := make(chan struct{})
+	p, _ := NewWorkerPool(func(ctx context.Context, n int) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, WorkerConfig{Workers: 1, QueueSize: 4})
+	running, _ := p.Submit(context.Background(), 1)
+	<-started
+	queued, _ := p.Submit(context.Background(), 2)
+	p.StopNow()
+	if _, err := running.Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("running task = %v", err)
+	}
+	if _, err := queued.Get(context.Background()); !errors.Is(err, ErrStopped) {
+		t.Fatalf("queued task = %v", err)
+	}
+}
+
+func TestWorkerPoolResize(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		cur     int
+		maxSeen int
+	)
+	p, _ := NewWorkerPool(func(_ context.Context, n int) (int, error) {
+		mu.Lock()
+		cur++
+		if cur > maxSeen {
+			maxSeen = cur
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		cur--
+		mu.Unlock()
+		return n, nil
+	}, WorkerConfig{Workers: 2, QueueSize: 100})
+
+	run := func(n int) int {
+		mu.Lock()
+		maxSeen = 0
+		mu.Unlock()
+		var fs []*future.Future[int]
+		for i := 0; i < n; i++ {
+			f, _ := p.Submit(context.Background(), i)
+			fs = append(fs, f)
+		}
+		for _, f := range fs {
+			f.Get(context.Background())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return maxSeen
+	}
+	if got := run(40); got > 2 {
+		t.Fatalf("concurrency = %d with 2 workers", got)
+	}
+	p.Resize(8)
+	if got := run(80); got <= 2 || got > 8 {
+		t.Fatalf("concurrency = %d with 8 workers", got)
+	}
+	p.Resize(1)
+	time.Sleep(10 * time.Millisecond) // 等多余的 worker 退出
+	if got := run(20); got != 1 {
+		t.Fatalf("concurrency = %d with 1 worker", got)
+	}
+	if m := p.Metrics(); m.Workers != 1 {
+		t.Fatalf("metrics = %+v", m)
+	}
+	p.StopWait()
+}
+
+func TestWorkerPoolInvalidConfig(t *testing.T) {
+	if _, err := NewWorkerPool(square, WorkerConfig{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("NewWorkerPool = %v", err)
+	}
+}
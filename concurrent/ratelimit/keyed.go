@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// Keyed 按 key 分别限流，如 API 网关中每个客户端一个令牌桶
+// 第一次访问某个 key 时调用 newLimiter 创建它的限流器；超过 idle 没有访问的 key 被淘汰，
+// 淘汰在访问时顺便进行（每 idle 最多扫描一次），不需要后台 goroutine
+// idle 应该不小于限流器恢复到初始状态的时间（如令牌桶的 burst/rate），否则淘汰之后重建的限流器会放过更多的请求
+type Keyed[K comparable] struct {
+	clock      clock.Clock
+	newLimiter func(K) Limiter
+	idle       time.Duration
+
+	mu        sync.Mutex
+	limiters  map[K]*keyedEntry
+	lastSweep time.Time
+	evicted   int64
+}
+
+type keyedEntry struct {
+	lim      Limiter
+	lastUsed time.Time
+}
+
+// NewKeyed 创建按 key 限流的限流器，idle <= 0 表示不淘汰；WithClock 的时钟用于计算空闲时间
+func NewKeyed[K comparable](newLimiter func(key K) Limiter, idle time.Duration, opts ...Option) *Keyed[K] {
+	cfg := newConfig(opts)
+	return &Keyed[K]{
+		clock:      cfg.clock,
+		newLimiter: newLimiter,
+		idle:       idle,
+		limiters:   make(map[K]*keyedEntry),
+		lastSweep:  cfg.clock.Now(),
+	}
+}
+
+// Allow key 现在是否可以通过
+func (k *Keyed[K]) Allow(key K) bool {
+	return k.get(key).Allow()
+}
+
+// Wait 等待到 key 可以通过
+func (k *Keyed[K]) Wait(ctx context.Context, key K) error {
+	return k.get(key).Wait(ctx)
+}
+
+// Limiter 返回 key 的限流器，不存在时创建，用于调用 TokenBucket.Reserve 等具体类型的方法
+func (k *Keyed[K]) Limiter(key K) Limiter {
+	return k.get(key)
+}
+
+// Len 现在保存的 key 的数量
+func (k *Keyed[K]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.limiters)
+}
+
+// Evicted 已经淘汰的 key 的数量
+func (k *Keyed[K]) Evicted() int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.evicted
+}
+
+func (k *Keyed[K]) get(key K) Limiter {
+	now := k.clock.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.idle > 0 && now.Sub(k.lastSweep) >= k.idle {
+		k.sweep(now)
+	}
+	e, ok := k.limiters[key]
+	if !ok {
+		e = &keyedEntry{lim: k.newLimiter(key)}
+		k.limiters[key] = e
+	}
+	e.lastUsed = now
+	return e.lim
+}
+
+// sweep 淘汰空闲的 key，需要持有 k.mu
+func (k *Keyed[K]) sweep(now time.Time) {
+	for key, e := range k.limiters {
+		if now.Sub(e.lastUsed) >= k.idle {
+			delete(k.limiters, key)
+			k.evicted++
+		}
+	}
+	k.lastSweep = now
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// LeakyBucket 漏桶，请求按 1/rate 的间隔均匀通过，与 uber-go/ratelimit 一致
+// 令牌桶允许一次通过 burst 个请求；漏桶把请求摊平，相邻两个请求之间至少间隔 per，
+// 只有空闲之后积累的 slack 个额度可以让请求连续通过，用于平滑对下游的压力
+type LeakyBucket struct {
+	clock clock.Clock
+	per   time.Duration // 相邻两个请求的间隔
+	slack time.Duration // 最多积累的额度
+
+	mu   sync.Mutex
+	next time.Time // 下一个请求最早可以通过的时间
+}
+
+// NewLeakyBucket 创建一个每秒通过 rate 个请求的漏桶
+func NewLeakyBucket(rate int, opts ...Option) *LeakyBucket {
+	if rate <= 0 {
+		panic("ratelimit: non-positive rate")
+	}
+	cfg := newConfig(opts)
+	per := time.Second / time.Duration(rate)
+	return &LeakyBucket{clock: cfg.clock, per: per, slack: time.Duration(cfg.slack) * per}
+}
+
+// Allow 现在是否可以通过
+func (b *LeakyBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	at := b.slot(now)
+	if at.After(now) {
+		return false
+	}
+	b.next = at.Add(b.per)
+	return true
+}
+
+// Wait 等待到可以通过，ctx 结束时归还占用的位置
+func (b *LeakyBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	now := b.clock.Now()
+	at := b.slot(now)
+	b.next = at.Add(b.per)
+	b.mu.Unlock()
+
+	if err := sleep(ctx, b.clock, at.Sub(now)); err != nil {
+		b.mu.Lock()
+		if b.next.Equal(at.Add(b.per)) { // 后面没有人排队时才能归还
+			b.next = at
+		}
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// slot 现在到达的请求可以通过的时间，需要持有 b.mu
+func (b *LeakyBucket) slot(now time.Time) time.Time {
+	if earliest := now.Add(-b.slack); b.next.Before(earliest) { // 空闲太久，最多积累 slack
+		return earliest
+	}
+	return b.next
+}
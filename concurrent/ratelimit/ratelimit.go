@@ -0,0 +1,78 @@
+// Package ratelimit 限流器，10.ratelimit 中 x/time/rate、juju/ratelimit、uber-go/ratelimit 的原生实现
+//
+//	TokenBucket    令牌桶：按速率补充令牌，允许 burst 大小的突发，支持 Allow、Reserve、Wait
+//	LeakyBucket    漏桶：请求按固定的间隔均匀通过，slack 允许空闲之后少量的突发（uber-go/ratelimit）
+//	SlidingLog     滑动窗口日志：记录窗口内每个请求的时间，精确，内存与 limit 成正比
+//	SlidingWindow  滑动窗口计数：用上一个固定窗口的计数按时间加权估计，O(1) 内存，是近似值
+//	Keyed          按 key（如客户端 IP、API Key）分别限流，空闲的 key 被淘汰
+//
+// 所有的限流器都可以通过 WithClock 使用 clock.Fake 测试
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+var (
+	// ErrExceedsBurst 一次请求的数量超过了 burst，永远不可能满足
+	ErrExceedsBurst = errors.New("ratelimit: n exceeds burst")
+	// ErrWouldExceedDeadline 需要等待的时间超过了 ctx 的截止时间，不再等待
+	ErrWouldExceedDeadline = errors.New("ratelimit: wait would exceed context deadline")
+)
+
+// Limiter 限流器的公共接口
+type Limiter interface {
+	// Allow 现在是否允许一个请求通过，不等待
+	Allow() bool
+	// Wait 等待到允许一个请求通过，或者 ctx 结束
+	Wait(ctx context.Context) error
+}
+
+type config struct {
+	clock clock.Clock
+	slack int
+}
+
+// Option 限流器的选项
+type Option func(*config)
+
+// WithClock 使用 c 作为时钟，默认为 clock.Real
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+// WithSlack 漏桶空闲之后最多积累 n 个请求的额度，默认为 10，与 uber-go/ratelimit 一致；0 表示严格均匀
+func WithSlack(n int) Option {
+	return func(cfg *config) { cfg.slack = n }
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{clock: clock.Real, slack: 10}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// sleep 使用时钟等待 d，ctx 结束时返回 ctx.Err()
+// ctx 的截止时间早于 d 时直接返回 ErrWouldExceedDeadline，不白等
+func sleep(ctx context.Context, c clock.Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return ErrWouldExceedDeadline
+	}
+	t := c.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,238 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+var (
+	_ Limiter = (*TokenBucket)(nil)
+	_ Limiter = (*LeakyBucket)(nil)
+	_ Limiter = (*SlidingLog)(nil)
+	_ Limiter = (*SlidingWindow)(nil)
+)
+
+func newFake() *clock.Fake { return clock.NewFake(time.Unix(0, 0)) }
+
+// count 连续调用 Allow，返回通过的数量
+func count(l Limiter, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if l.Allow() {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// waitAsync 在新的 goroutine 中调用 Wait，等它阻塞在时钟上
+func waitAsync(c *clock.Fake, l Limiter) <-chan error {
+	errc := make(chan error, 1)
+	go func() { errc <- l.Wait(context.Background()) }()
+	c.BlockUntil(1)
+	return errc
+}
+
+func TestTokenBucket(t *testing.T) {
+	c := newFake()
+	b := NewTokenBucket(10, 5, WithClock(c))
+	if got := count(b, 10); got != 5 {
+		t.Fatalf("burst allowed %d, want 5", got)
+	}
+	c.Advance(100 * time.Millisecond)
+	if got := count(b, 10); got != 1 {
+		t.Fatalf("after 100ms allowed %d, want 1", got)
+	}
+	c.Advance(time.Hour) // 不会超过 burst
+	if got := b.Tokens(); got != 5 {
+		t.Fatalf("tokens = %v, want 5", got)
+	}
+	if !b.AllowN(5) || b.AllowN(1) {
+		t.Fatal("AllowN")
+	}
+
+	// Reserve 预订将来的令牌，Cancel 归还
+	r1 := b.Reserve()
+	r2 := b.Reserve()
+	if !r1.OK() || r1.Delay() != 100*time.Millisecond || r2.Delay() != 200*time.Millisecond {
+		t.Fatalf("delays = %v, %v", r1.Delay(), r2.Delay())
+	}
+	r2.Cancel()
+	if r3 := b.Reserve(); r3.Delay() != 200*time.Millisecond {
+		t.Fatalf("delay after cancel = %v", r3.Delay())
+	}
+	if b.ReserveN(6).OK() {
+		t.Fatal("reservation exceeding burst succeeded")
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	c := newFake()
+	b := NewTokenBucket(10, 1, WithClock(c))
+	if err := b.Wait(context.Background()); err != nil { // 桶是满的，不等待
+		t.Fatal(err)
+	}
+	errc := waitAsync(c, b)
+	c.Advance(99 * time.Millisecond)
+	select {
+	case <-errc:
+		t.Fatal("Wait returned early")
+	default:
+	}
+	c.Advance(time.Millisecond)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.WaitN(context.Background(), 2); !errors.Is(err, ErrExceedsBurst) {
+		t.Fatalf("WaitN = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("Wait = %v", err)
+	}
+	if got := b.Tokens(); got != 0 { // 失败的 Wait 归还了令牌
+		t.Fatalf("tokens = %v", got)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v", err)
+	}
+}
+
+func TestLeakyBucket(t *testing.T) {
+	c := newFake()
+	b := NewLeakyBucket(10, WithClock(c), WithSlack(0))
+	if got := count(b, 5); got != 1 {
+		t.Fatalf("allowed %d, want 1", got)
+	}
+	c.Advance(50 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("allowed before interval")
+	}
+	c.Advance(50 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("not allowed after interval")
+	}
+
+	// Wait 按间隔均匀通过
+	var start = c.Now()
+	for i := 1; i <= 3; i++ {
+		errc := waitAsync(c, b)
+		c.Advance(100 * time.Millisecond)
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Since(start); got != time.Duration(i)*100*time.Millisecond {
+			t.Fatalf("request %d at %v", i, got)
+		}
+	}
+
+	// slack 积累空闲时的额度
+	b = NewLeakyBucket(10, WithClock(c), WithSlack(3))
+	c.Advance(time.Hour)
+	if got := count(b, 10); got != 4 {
+		t.Fatalf("allowed %d with slack 3, want 4", got)
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	c := newFake()
+	l := NewSlidingLog(3, time.Second, WithClock(c))
+	l.Allow()
+	c.Advance(400 * time.Millisecond)
+	if got := count(l, 5); got != 2 {
+		t.Fatalf("allowed %d, want 2", got)
+	}
+	c.Advance(599 * time.Millisecond)
+	if l.Allow() {
+		t.Fatal("allowed before the first request left the window")
+	}
+	c.Advance(time.Millisecond)
+	if got := count(l, 5); got != 1 {
+		t.Fatalf("allowed %d, want 1", got)
+	}
+
+	errc := waitAsync(c, l) // 等 400ms 时的请求移出窗口
+	c.Advance(400 * time.Millisecond)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	c := newFake()
+	w := NewSlidingWindow(10, time.Second, WithClock(c))
+	if got := count(w, 20); got != 10 {
+		t.Fatalf("allowed %d, want 10", got)
+	}
+	// 下一个窗口的一半：上一个窗口的 10 个按一半计算，还可以通过 5 个
+	c.Advance(1500 * time.Millisecond)
+	if got := count(w, 20); got != 5 {
+		t.Fatalf("allowed %d, want 5", got)
+	}
+	// 再过 200ms，上一个窗口的权重为 0.3，估计为 3+5，还可以通过 2 个
+	c.Advance(200 * time.Millisecond)
+	if got := count(w, 20); got != 2 {
+		t.Fatalf("allowed %d, want 2", got)
+	}
+
+	errc := waitAsync(c, w)
+	c.Advance(100 * time.Millisecond) // 权重下降到 0.2：2+7 < 10
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(10 * time.Second) // 空闲超过两个窗口
+	if got := count(w, 20); got != 10 {
+		t.Fatalf("allowed %d after idle, want 10", got)
+	}
+}
+
+func TestKeyed(t *testing.T) {
+	c := newFake()
+	k := NewKeyed(func(string) Limiter { return NewTokenBucket(1, 2, WithClock(c)) }, time.Minute, WithClock(c))
+	if got := count(keyLimiter{k, "a"}, 5); got != 2 {
+		t.Fatalf("a allowed %d, want 2", got)
+	}
+	if got := count(keyLimiter{k, "b"}, 5); got != 2 { // 每个 key 独立
+		t.Fatalf("b allowed %d, want 2", got)
+	}
+	if k.Len() != 2 {
+		t.Fatalf("Len = %d", k.Len())
+	}
+	if _, ok := k.Limiter("a").(*TokenBucket); !ok {
+		t.Fatal("Limiter returned wrong type")
+	}
+
+	c.Advance(30 * time.Second)
+	k.Allow("a")
+	c.Advance(40 * time.Second) // b 空闲 70s，a 空闲 40s
+	k.Allow("c")
+	if k.Len() != 2 || k.Evicted() != 1 {
+		t.Fatalf("Len = %d, Evicted = %d", k.Len(), k.Evicted())
+	}
+
+	k.Allow("c") // c 的令牌用完，Wait 等待补充
+	errc := make(chan error, 1)
+	go func() { errc <- k.Wait(context.Background(), "c") }()
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+type keyLimiter struct {
+	k   *Keyed[string]
+	key string
+}
+
+func (l keyLimiter) Allow() bool                    { return l.k.Allow(l.key) }
+func (l keyLimiter) Wait(ctx context.Context) error { return l.k.Wait(ctx, l.key) }
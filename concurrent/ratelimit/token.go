@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// TokenBucket 令牌桶，每秒补充 rate 个令牌，最多保存 burst 个
+// 令牌在访问时按经过的时间计算，不需要后台 goroutine；令牌可以是负数，表示已经被预订的将来的令牌
+type TokenBucket struct {
+	clock clock.Clock
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // tokens 对应的时间
+}
+
+// NewTokenBucket 创建一个每秒 rate 个令牌、容量为 burst 的令牌桶，初始是满的
+func NewTokenBucket(rate float64, burst int, opts ...Option) *TokenBucket {
+	if rate <= 0 || burst <= 0 {
+		panic("ratelimit: non-positive rate or burst")
+	}
+	cfg := newConfig(opts)
+	return &TokenBucket{clock: cfg.clock, rate: rate, burst: burst, tokens: float64(burst), last: cfg.clock.Now()}
+}
+
+// Rate 每秒补充的令牌数
+func (b *TokenBucket) Rate() float64 { return b.rate }
+
+// Burst 桶的容量
+func (b *TokenBucket) Burst() int { return b.burst }
+
+// Tokens 现在可用的令牌数，有预订时为负数
+func (b *TokenBucket) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.clock.Now())
+	return b.tokens
+}
+
+// Allow 现在是否有一个令牌
+func (b *TokenBucket) Allow() bool { return b.AllowN(1) }
+
+// AllowN 现在是否有 n 个令牌，有时取走
+func (b *TokenBucket) AllowN(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.clock.Now())
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// Reserve 预订一个令牌
+func (b *TokenBucket) Reserve() *Reservation { return b.ReserveN(1) }
+
+// ReserveN 预订 n 个令牌，总是立即返回：Delay 为需要等待的时间，n 超过 burst 时 OK 为 false
+// 调用者等待 Delay 之后再执行；不执行时调用 Cancel 归还令牌
+func (b *TokenBucket) ReserveN(n int) *Reservation {
+	now := b.clock.Now()
+	if n > b.burst {
+		return &Reservation{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.tokens -= float64(n)
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = durationFromTokens(-b.tokens, b.rate)
+	}
+	return &Reservation{ok: true, b: b, n: n, at: now.Add(delay), delay: delay}
+}
+
+// Wait 等待一个令牌
+func (b *TokenBucket) Wait(ctx context.Context) error { return b.WaitN(ctx, 1) }
+
+// WaitN 等待 n 个令牌，ctx 结束或者截止时间之前等不到时返回错误，预订的令牌被归还
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r := b.ReserveN(n)
+	if !r.OK() {
+		return ErrExceedsBurst
+	}
+	if err := sleep(ctx, b.clock, r.Delay()); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+// advance 补充从 b.last 到 now 的令牌，需要持有 b.mu
+func (b *TokenBucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+func durationFromTokens(tokens, rate float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
+
+// Reservation TokenBucket.ReserveN 的结果
+type Reservation struct {
+	ok       bool
+	b        *TokenBucket
+	n        int
+	at       time.Time // 可以执行的时间
+	delay    time.Duration
+	canceled bool
+}
+
+// OK 是否预订成功，n 超过 burst 时为 false
+func (r *Reservation) OK() bool { return r.ok }
+
+// Delay 预订时需要等待的时间
+func (r *Reservation) Delay() time.Duration { return r.delay }
+
+// Cancel 不再执行，归还还没有到期的令牌，使后面的请求可以提前
+// 已经到了执行时间的预订不归还，与 x/time/rate 的近似一致
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	b := r.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	if r.canceled || !now.Before(r.at) {
+		return
+	}
+	r.canceled = true
+	b.advance(now)
+	b.tokens = math.Min(float64(b.burst), b.tokens+float64(r.n))
+}
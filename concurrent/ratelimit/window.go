@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// SlidingLog 滑动窗口日志，任意长度为 window 的时间段内最多通过 limit 个请求
+// 保存窗口内每个请求的时间，结果是精确的，内存与 limit 成正比
+type SlidingLog struct {
+	clock  clock.Clock
+	limit  int
+	window time.Duration
+
+	mu   sync.Mutex
+	log  []time.Time // 环形缓冲区，按时间排序
+	head int
+	n    int
+}
+
+// NewSlidingLog 创建一个每 window 最多通过 limit 个请求的限流器
+func NewSlidingLog(limit int, window time.Duration, opts ...Option) *SlidingLog {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: non-positive limit or window")
+	}
+	cfg := newConfig(opts)
+	return &SlidingLog{clock: cfg.clock, limit: limit, window: window, log: make([]time.Time, limit)}
+}
+
+// Allow 现在是否可以通过
+func (l *SlidingLog) Allow() bool {
+	return l.take(l.clock.Now()) == 0
+}
+
+// Wait 等待到可以通过
+func (l *SlidingLog) Wait(ctx context.Context) error {
+	return waitLoop(ctx, l.clock, l.take)
+}
+
+// take 可以通过时记录并返回 0，否则返回还需要等待的时间
+func (l *SlidingLog) take(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.n > 0 && !now.Before(l.log[l.head].Add(l.window)) { // 移出窗口
+		l.head = (l.head + 1) % l.limit
+		l.n--
+	}
+	if l.n == l.limit {
+		return l.log[l.head].Add(l.window).Sub(now) // 等最早的一个移出窗口
+	}
+	l.log[(l.head+l.n)%l.limit] = now
+	l.n++
+	return 0
+}
+
+// SlidingWindow 滑动窗口计数，只保存当前和上一个固定窗口的计数，
+// 用上一个窗口的计数乘以它与滑动窗口重叠的比例，估计滑动窗口内的请求数
+// 假设上一个窗口内的请求是均匀分布的，所以是近似值，但只需要 O(1) 的内存
+type SlidingWindow struct {
+	clock  clock.Clock
+	limit  int
+	window time.Duration
+
+	mu    sync.Mutex
+	start time.Time // 当前固定窗口的开始时间
+	prev  int
+	cur   int
+}
+
+// NewSlidingWindow 创建一个每 window 最多通过约 limit 个请求的限流器
+func NewSlidingWindow(limit int, window time.Duration, opts ...Option) *SlidingWindow {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: non-positive limit or window")
+	}
+	cfg := newConfig(opts)
+	return &SlidingWindow{clock: cfg.clock, limit: limit, window: window, start: cfg.clock.Now()}
+}
+
+// Allow 现在是否可以通过
+func (w *SlidingWindow) Allow() bool {
+	return w.take(w.clock.Now()) == 0
+}
+
+// Wait 等待到可以通过
+func (w *SlidingWindow) Wait(ctx context.Context) error {
+	return waitLoop(ctx, w.clock, w.take)
+}
+
+// take 可以通过时计数并返回 0，否则返回估计的还需要等待的时间
+func (w *SlidingWindow) take(now time.Time) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if elapsed := now.Sub(w.start); elapsed >= w.window { // 进入新的固定窗口
+		if elapsed >= 2*w.window {
+			w.prev = 0
+		} else {
+			w.prev = w.cur
+		}
+		w.cur = 0
+		w.start = w.start.Add(elapsed / w.window * w.window)
+	}
+	elapsed := now.Sub(w.start)
+	weight := 1 - float64(elapsed)/float64(w.window) // 上一个窗口与滑动窗口重叠的比例
+	if float64(w.prev)*weight+float64(w.cur) < float64(w.limit) {
+		w.cur++
+		return 0
+	}
+	if w.cur >= w.limit || w.prev == 0 { // 当前窗口已经满了，等下一个窗口
+		return w.window - elapsed
+	}
+	// 重叠的比例下降到 (limit-cur)/prev 时可以通过
+	need := 1 - float64(w.limit-w.cur)/float64(w.prev)
+	d := time.Duration(need*float64(w.window)) - elapsed
+	if d <= 0 {
+		d = 1 // 浮点误差
+	}
+	return d
+}
+
+// waitLoop 反复调用 take，等待它返回的时间，直到可以通过
+// 等待期间可能被其它请求抢先，所以醒来之后要重新检查
+func waitLoop(ctx context.Context, c clock.Clock, take func(time.Time) time.Duration) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d := take(c.Now())
+		if d == 0 {
+			return nil
+		}
+		if err := sleep(ctx, c, d); err != nil {
+			return err
+		}
+	}
+}
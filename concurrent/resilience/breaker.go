@@ -0,0 +1,246 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// State 熔断器的状态
+type State int
+
+const (
+	Closed   State = iota // 关闭：正常调用，统计失败率
+	Open                  // 打开：拒绝所有调用
+	HalfOpen              // 半开：放少量的探测请求
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// BreakerConfig 熔断器的配置，零值的字段使用默认值
+type BreakerConfig struct {
+	Name string // 在 OnStateChange 中区分不同的熔断器
+
+	Window      time.Duration // 统计失败率的滚动窗口，默认 10s
+	Buckets     int           // 窗口分成的桶数，过期的桶整个丢弃，默认 10
+	MinRequests int           // 窗口内的请求数达到这个数时才计算失败率，默认 20
+	FailureRate float64       // 失败率达到这个值时打开，(0, 1]，默认 0.5
+
+	OpenTimeout    time.Duration // 打开之后多久进入半开状态，默认 30s
+	HalfOpenProbes int           // 半开状态同时允许的探测请求数，都成功时关闭，默认 1
+
+	// IsFailure 判断调用的错误是否算作失败，默认 err != nil 且不是 context.Canceled
+	// 调用方取消的请求不说明依赖出了故障
+	IsFailure func(err error) bool
+	// OnStateChange 状态变化时调用，在持有内部锁时调用，不能调用 CircuitBreaker 的方法
+	OnStateChange func(name string, from, to State)
+	// Clock 默认为 clock.Real
+	Clock clock.Clock
+}
+
+// Counts 滚动窗口内的请求统计
+type Counts struct {
+	Requests int
+	Failures int
+}
+
+type bucket struct {
+	start time.Time
+	Counts
+}
+
+// CircuitBreaker 熔断器
+type CircuitBreaker struct {
+	cfg        BreakerConfig
+	bucketSize time.Duration
+
+	mu         sync.Mutex
+	state      State
+	generation uint64    // 每次状态变化加一，忽略上一个状态时开始的调用的结果
+	openedAt   time.Time // 最近一次打开的时间
+	buckets    []bucket  // 环形的滚动窗口
+	probes     int       // 半开状态正在进行的探测请求数
+	successes  int       // 半开状态成功的探测请求数
+}
+
+// NewCircuitBreaker 创建熔断器，初始为关闭状态
+func NewCircuitBreaker(cfg BreakerConfig) (*CircuitBreaker, error) {
+	if cfg.Window == 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Buckets == 0 {
+		cfg.Buckets = 10
+	}
+	if cfg.MinRequests == 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.FailureRate == 0 {
+		cfg.FailureRate = 0.5
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes == 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool { return err != nil && !errors.Is(err, context.Canceled) }
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
+	if cfg.Window < 0 || cfg.Buckets < 0 || cfg.MinRequests < 0 || cfg.FailureRate < 0 || cfg.FailureRate > 1 ||
+		cfg.OpenTimeout < 0 || cfg.HalfOpenProbes < 0 || cfg.Window/time.Duration(cfg.Buckets) == 0 {
+		return nil, ErrInvalidConfig
+	}
+	return &CircuitBreaker{
+		cfg:        cfg,
+		bucketSize: cfg.Window / time.Duration(cfg.Buckets),
+		buckets:    make([]bucket, cfg.Buckets),
+	}, nil
+}
+
+// Acquire 请求一次调用：打开时返回 ErrOpen，半开且探测请求已满时返回 ErrTooManyProbes
+// 成功时调用结束后必须调用 done 报告结果
+func (cb *CircuitBreaker) Acquire(ctx context.Context) (done func(err error), err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	now := cb.cfg.Clock.Now()
+	cb.refresh(now)
+	switch cb.state {
+	case Open:
+		return nil, ErrOpen
+	case HalfOpen:
+		if cb.probes >= cb.cfg.HalfOpenProbes {
+			return nil, ErrTooManyProbes
+		}
+		cb.probes++
+	}
+	generation := cb.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { cb.done(generation, cb.cfg.IsFailure(err)) })
+	}, nil
+}
+
+// State 当前的状态
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.refresh(cb.cfg.Clock.Now())
+	return cb.state
+}
+
+// Counts 滚动窗口内的统计，只统计关闭状态的调用
+func (cb *CircuitBreaker) Counts() Counts {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.counts(cb.cfg.Clock.Now())
+}
+
+// RetryAfter 打开状态还要多久进入半开状态，不是打开状态时为 0
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	now := cb.cfg.Clock.Now()
+	cb.refresh(now)
+	if cb.state != Open {
+		return 0
+	}
+	return cb.openedAt.Add(cb.cfg.OpenTimeout).Sub(now)
+}
+
+func (cb *CircuitBreaker) done(generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	now := cb.cfg.Clock.Now()
+	cb.refresh(now)
+	if generation != cb.generation { // 调用开始之后状态已经变了
+		return
+	}
+	switch cb.state {
+	case Closed:
+		b := cb.bucket(now)
+		b.Requests++
+		if failed {
+			b.Failures++
+		}
+		c := cb.counts(now)
+		if c.Requests >= cb.cfg.MinRequests && float64(c.Failures) >= cb.cfg.FailureRate*float64(c.Requests) {
+			cb.setState(Open, now)
+		}
+	case HalfOpen:
+		cb.probes--
+		if failed {
+			cb.setState(Open, now)
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.cfg.HalfOpenProbes {
+			cb.setState(Closed, now)
+		}
+	}
+}
+
+// refresh 打开超过 OpenTimeout 时进入半开状态，需要持有 cb.mu
+func (cb *CircuitBreaker) refresh(now time.Time) {
+	if cb.state == Open && !now.Before(cb.openedAt.Add(cb.cfg.OpenTimeout)) {
+		cb.setState(HalfOpen, now)
+	}
+}
+
+// setState 需要持有 cb.mu
+func (cb *CircuitBreaker) setState(to State, now time.Time) {
+	from := cb.state
+	cb.state = to
+	cb.generation++
+	cb.probes, cb.successes = 0, 0
+	switch to {
+	case Open:
+		cb.openedAt = now
+	case Closed:
+		clear(cb.buckets) // 重新开始统计
+	}
+	if cb.cfg.OnStateChange != nil {
+		cb.cfg.OnStateChange(cb.cfg.Name, from, to)
+	}
+}
+
+// bucket 返回 now 所在的桶，桶过期时清零，需要持有 cb.mu
+func (cb *CircuitBreaker) bucket(now time.Time) *bucket {
+	start := now.Truncate(cb.bucketSize)
+	n := int64(len(cb.buckets))
+	i := (start.UnixNano()/int64(cb.bucketSize)%n + n) % n // 1970 年之前 UnixNano 为负数
+	b := &cb.buckets[i]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+// counts 汇总窗口内没有过期的桶，需要持有 cb.mu
+func (cb *CircuitBreaker) counts(now time.Time) Counts {
+	var c Counts
+	oldest := now.Truncate(cb.bucketSize).Add(-cb.bucketSize * time.Duration(len(cb.buckets)-1))
+	for _, b := range cb.buckets {
+		if !b.start.Before(oldest) {
+			c.Requests += b.Requests
+			c.Failures += b.Failures
+		}
+	}
+	return c
+}
@@ -0,0 +1,94 @@
+package resilience
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+// BulkheadConfig 舱壁的配置
+type BulkheadConfig struct {
+	MaxConcurrent int           // 同时进行的调用数的上限，必须大于 0
+	MaxWait       time.Duration // 已满时最多等待多久，0 表示不等待，立即返回 ErrBulkheadFull
+	Clock         clock.Clock   // 默认为 clock.Real
+}
+
+// BulkheadStats 舱壁的统计数据
+type BulkheadStats struct {
+	MaxConcurrent int
+	InUse         int   // 正在进行的调用数
+	Rejected      int64 // 被拒绝的调用数
+}
+
+// Bulkhead 限制对一个依赖的并发调用数，每个依赖使用一个单独的 Bulkhead
+type Bulkhead struct {
+	maxWait  time.Duration
+	clock    clock.Clock
+	sema     chan struct{}
+	rejected atomic.Int64
+}
+
+// NewBulkhead 创建舱壁
+func NewBulkhead(cfg BulkheadConfig) (*Bulkhead, error) {
+	if cfg.MaxConcurrent <= 0 || cfg.MaxWait < 0 {
+		return nil, ErrInvalidConfig
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
+	return &Bulkhead{
+		maxWait: cfg.MaxWait,
+		clock:   cfg.Clock,
+		sema:    make(chan struct{}, cfg.MaxConcurrent),
+	}, nil
+}
+
+// Acquire 请求一次调用，已满时最多等待 MaxWait，超时返回 ErrBulkheadFull，ctx 结束返回 ctx.Err()
+// 调用结束后必须调用 done，舱壁不关心调用的结果
+func (b *Bulkhead) Acquire(ctx context.Context) (done func(err error), err error) {
+	select {
+	case b.sema <- struct{}{}:
+		return b.release(), nil
+	default:
+	}
+	if b.maxWait <= 0 {
+		b.rejected.Add(1)
+		return nil, ErrBulkheadFull
+	}
+	timer := b.clock.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.sema <- struct{}{}:
+		return b.release(), nil
+	case <-timer.C():
+		b.rejected.Add(1)
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// RetryAfter 被拒绝的调用建议多久之后重试：已经等了 MaxWait 都没有空位，至少再等一个 MaxWait
+func (b *Bulkhead) RetryAfter() time.Duration {
+	return b.maxWait
+}
+
+// Stats 返回当前的统计数据
+func (b *Bulkhead) Stats() BulkheadStats {
+	return BulkheadStats{
+		MaxConcurrent: cap(b.sema),
+		InUse:         len(b.sema),
+		Rejected:      b.rejected.Load(),
+	}
+}
+
+func (b *Bulkhead) release() func(error) {
+	var released atomic.Bool
+	return func(error) {
+		if released.CompareAndSwap(false, true) {
+			<-b.sema
+		}
+	}
+}
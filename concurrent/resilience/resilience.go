@@ -0,0 +1,61 @@
+// Package resilience 故障隔离：熔断器和舱壁，begin/05&09.architectural/面向错误的设计.go 中“断路器”“隔离”的实现
+//
+//	CircuitBreaker  熔断器：滚动窗口内的失败率超过阈值时打开，快速失败而不是等待一个已经出故障的依赖；
+//	                打开一段时间后进入半开状态，放少量的探测请求，成功则关闭，失败则重新打开
+//	Bulkhead        舱壁：限制对一个依赖的并发调用数，一个依赖变慢时只占满自己的舱壁，不会耗尽所有的 goroutine 和连接
+//
+// 两者都实现 Guard，Do、Wrap 用它们保护任意类型的调用，可以嵌套组合：
+//
+//	get := resilience.Wrap(breaker, resilience.Wrap(bulkhead, client.Get))
+//
+// HTTP 中间件见 modes/07.decoration_resilience.go
+package resilience
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrOpen 熔断器处于打开状态，调用被拒绝
+	ErrOpen = errors.New("resilience: circuit breaker is open")
+	// ErrTooManyProbes 熔断器处于半开状态，探测请求已经达到上限
+	ErrTooManyProbes = errors.New("resilience: too many requests in half-open state")
+	// ErrBulkheadFull 舱壁已满，等待超时
+	ErrBulkheadFull = errors.New("resilience: bulkhead is full")
+	// ErrInvalidConfig 配置不合法
+	ErrInvalidConfig = errors.New("resilience: invalid config")
+)
+
+// Guard 保护一次调用：Acquire 成功后执行调用，调用结束后用它的错误调用 done
+type Guard interface {
+	Acquire(ctx context.Context) (done func(err error), err error)
+}
+
+// Do 在 g 的保护下执行 fn；g 拒绝时不执行 fn，返回 g 的错误
+// fn panic 时按失败报告给 g，然后继续 panic
+func Do[T any](ctx context.Context, g Guard, fn func(ctx context.Context) (T, error)) (v T, err error) {
+	done, err := g.Acquire(ctx)
+	if err != nil {
+		return v, err
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			done(errPanicked)
+		}
+	}()
+	v, err = fn(ctx)
+	finished = true
+	done(err)
+	return v, err
+}
+
+// Wrap 返回在 g 的保护下调用 fn 的函数
+func Wrap[In, Out any](g Guard, fn func(ctx context.Context, in In) (Out, error)) func(ctx context.Context, in In) (Out, error) {
+	return func(ctx context.Context, in In) (Out, error) {
+		return Do(ctx, g, func(ctx context.Context) (Out, error) { return fn(ctx, in) })
+	}
+}
+
+var errPanicked = errors.New("resilience: call panicked")
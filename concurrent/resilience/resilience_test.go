@@ -0,0 +1,187 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+var (
+	_ Guard = (*CircuitBreaker)(nil)
+	_ Guard = (*Bulkhead)(nil)
+
+	errBackend = errors.New("backend error")
+)
+
+func call(g Guard, err error) error {
+	_, e := Do(context.Background(), g, func(context.Context) (int, error) { return 0, err })
+	return e
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	var changes []string
+	cb, err := NewCircuitBreaker(BreakerConfig{
+		Name:        "db",
+		MinRequests: 4,
+		FailureRate: 0.5,
+		OpenTimeout: 5 * time.Second,
+		OnStateChange: func(name string, from, to State) {
+			changes = append(changes, fmt.Sprintf("%s:%v->%v", name, from, to))
+		},
+		Clock: c,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call(cb, nil)
+	call(cb, errBackend)
+	call(cb, context.Canceled) // 调用方取消不算失败
+	if cb.State() != Closed || cb.Counts() != (Counts{Requests: 3, Failures: 1}) {
+		t.Fatalf("state = %v, counts = %+v", cb.State(), cb.Counts())
+	}
+	call(cb, errBackend) // 4 个请求 2 个失败
+	if cb.State() != Open {
+		t.Fatalf("state = %v, want open", cb.State())
+	}
+	if err := call(cb, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("call while open = %v", err)
+	}
+	c.Advance(2 * time.Second)
+	if got := cb.RetryAfter(); got != 3*time.Second {
+		t.Fatalf("RetryAfter = %v", got)
+	}
+
+	// 半开：只放一个探测请求，失败时重新打开
+	c.Advance(3 * time.Second)
+	done, err := cb.Acquire(context.Background())
+	if err != nil || cb.State() != HalfOpen {
+		t.Fatalf("probe err = %v, state = %v", err, cb.State())
+	}
+	if _, err := cb.Acquire(context.Background()); !errors.Is(err, ErrTooManyProbes) {
+		t.Fatalf("second probe = %v", err)
+	}
+	done(errBackend)
+	if cb.State() != Open {
+		t.Fatalf("state after failed probe = %v", cb.State())
+	}
+
+	// 探测成功时关闭，重新开始统计
+	c.Advance(5 * time.Second)
+	if err := call(cb, nil); err != nil || cb.State() != Closed || cb.Counts() != (Counts{}) {
+		t.Fatalf("err = %v, state = %v, counts = %+v", err, cb.State(), cb.Counts())
+	}
+
+	want := []string{"db:closed->open", "db:open->half-open", "db:half-open->open", "db:open->half-open", "db:half-open->closed"}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Fatalf("changes = %v", changes)
+	}
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	cb, _ := NewCircuitBreaker(BreakerConfig{Window: 10 * time.Second, Buckets: 10, MinRequests: 3, Clock: c})
+
+	call(cb, errBackend)
+	call(cb, errBackend)
+	c.Advance(10 * time.Second) // 前两个失败移出窗口
+	call(cb, errBackend)
+	if cb.State() != Closed || cb.Counts().Requests != 1 {
+		t.Fatalf("state = %v, counts = %+v", cb.State(), cb.Counts())
+	}
+
+	c.Advance(5 * time.Second)
+	call(cb, nil)
+	c.Advance(4 * time.Second)
+	call(cb, errBackend) // 窗口内 3 个请求 2 个失败
+	if cb.State() != Open {
+		t.Fatalf("state = %v, counts = %+v", cb.State(), cb.Counts())
+	}
+
+	// 打开之前开始的调用的结果被忽略
+	cb2, _ := NewCircuitBreaker(BreakerConfig{MinRequests: 1, Clock: c})
+	slow, _ := cb2.Acquire(context.Background())
+	call(cb2, errBackend)
+	slow(nil)
+	if cb2.State() != Open {
+		t.Fatalf("stale result changed state to %v", cb2.State())
+	}
+
+	if _, err := NewCircuitBreaker(BreakerConfig{FailureRate: 2}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("invalid config err = %v", err)
+	}
+}
+
+func TestCircuitBreakerBefore1970(t *testing.T) {
+	c := clock.NewFake(time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC))
+	cb, _ := NewCircuitBreaker(BreakerConfig{Window: 10 * time.Second, Buckets: 10, MinRequests: 3, Clock: c})
+	for i := 0; i < 3; i++ { // 每个请求落在不同的桶
+		call(cb, errBackend)
+		c.Advance(time.Second)
+	}
+	if cb.State() != Open {
+		t.Fatalf("state = %v, counts = %+v", cb.State(), cb.Counts())
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	b, err := NewBulkhead(BulkheadConfig{MaxConcurrent: 2, Clock: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d1, _ := b.Acquire(context.Background())
+	d2, _ := b.Acquire(context.Background())
+	if err := call(b, nil); !errors.Is(err, ErrBulkheadFull) {
+		t.Fatalf("call when full = %v", err)
+	}
+	d1(nil)
+	d1(nil) // 重复调用 done 没有影响
+	if err := call(b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.Stats(); s != (BulkheadStats{MaxConcurrent: 2, InUse: 1, Rejected: 1}) {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// 已满时等待 MaxWait
+	w, _ := NewBulkhead(BulkheadConfig{MaxConcurrent: 1, MaxWait: time.Second, Clock: c})
+	hold, _ := w.Acquire(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- call(w, nil) }()
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	if err := <-errc; !errors.Is(err, ErrBulkheadFull) {
+		t.Fatalf("wait timeout = %v", err)
+	}
+	go func() { errc <- call(w, nil) }()
+	c.BlockUntil(1)
+	hold(nil)
+	if err := <-errc; err != nil {
+		t.Fatalf("wait = %v", err)
+	}
+	d2(nil)
+}
+
+func TestDoPanic(t *testing.T) {
+	cb, _ := NewCircuitBreaker(BreakerConfig{MinRequests: 1, Clock: clock.NewFake(time.Unix(0, 0))})
+	get := Wrap(cb, func(ctx context.Context, key string) (string, error) {
+		panic("boom")
+	})
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recovered %v", r)
+			}
+		}()
+		get(context.Background(), "k")
+	}()
+	if cb.State() != Open { // panic 算作失败
+		t.Fatalf("state = %v", cb.State())
+	}
+}
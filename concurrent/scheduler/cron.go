@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCron cron 表达式的格式错误
+var ErrInvalidCron = errors.New("scheduler: invalid cron expression")
+
+// CronSchedule 标准的 5 个字段的 cron 计划
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64 // 每个字段允许的值的位图
+	domStar, dowStar              bool   // 日和星期是否以 * 开头
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day of week", min: 0, max: 7, names: map[string]int{ // 0 和 7 都是星期日
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron 解析 cron 表达式：分 时 日 月 星期
+//
+//	每个字段可以是 *、数字、范围 a-b、步长 */n、a-b/n、a/n，用逗号分隔多个；月和星期可以用英文缩写（JAN、MON）
+//	星期的 0 和 7 都是星期日
+//	日和星期都不是 * 时，满足其中一个即可（与 Vixie cron 一致）
+//	也可以使用 @yearly、@monthly、@weekly、@daily、@hourly
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w: %q has %d fields, want 5", ErrInvalidCron, expr, len(fields))
+	}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := cronFields[i].parse(f)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &CronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parse 解析一个字段，返回允许的值的位图
+func (f cronField) parse(s string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step, hasStep := part, 1, false
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, f.errorf("bad step in %q", part)
+			}
+			rng, step, hasStep = part[:i], n, true
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.IndexByte(rng, '-')
+			var err error
+			if lo, err = f.value(rng[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rng[i+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, f.errorf("bad range %q", rng)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep { // a/n 表示从 a 到最大值，包括 a/1
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, f.errorf("bad value %q", s)
+	}
+	return v, nil
+}
+
+func (f cronField) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidCron, f.name, fmt.Sprintf(format, args...))
+}
+
+// Next t 之后的下一个计划时间（整分钟），5 年内没有时返回零值（如 2 月 30 日）
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(c.month, int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !has(c.hour, t.Hour()):
+			// 按绝对时间前进，夏令时切换时不会回到已经检查过的时间
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(set uint64, v int) bool { return set&(1<<v) != 0 }
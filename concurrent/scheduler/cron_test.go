@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "* * * foo *",
+	} {
+		if _, err := ParseCron(expr); !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("ParseCron(%q) err = %v", expr, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC) // 星期一
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"* * * * *", []string{"2024-01-01 00:01", "2024-01-01 00:02"}},
+		{"*/20 * * * *", []string{"2024-01-01 00:20", "2024-01-01 00:40", "2024-01-01 01:00"}},
+		{"30 9-10 * * *", []string{"2024-01-01 09:30", "2024-01-01 10:30", "2024-01-02 09:30"}},
+		{"0 0 * * sat,7", []string{"2024-01-06 00:00", "2024-01-07 00:00", "2024-01-13 00:00"}},
+		{"0 12 29 feb *", []string{"2024-02-29 12:00", "2028-02-29 12:00"}},
+		{"0 0 13 * fri", []string{"2024-01-05 00:00", "2024-01-12 00:00", "2024-01-13 00:00"}}, // 日和星期满足一个即可
+		{"0 0 */10 * 1", []string{"2024-03-11 00:00", "2024-04-01 00:00"}},                     // 以 * 开头，两个都要满足
+		{"15/20 0 * * *", []string{"2024-01-01 00:15", "2024-01-01 00:35", "2024-01-01 00:55"}},
+		{"58/1 0 * * *", []string{"2024-01-01 00:58", "2024-01-01 00:59", "2024-01-02 00:58"}},
+		{"@monthly", []string{"2024-02-01 00:00", "2024-03-01 00:00"}},
+	}
+	for _, tt := range tests {
+		sched, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		next := from
+		for _, want := range tt.want {
+			next = sched.Next(next)
+			if got := next.Format("2006-01-02 15:04"); got != want {
+				t.Errorf("%q: got %s, want %s", tt.expr, got, want)
+				break
+			}
+		}
+	}
+
+	never, _ := ParseCron("0 0 30 feb *")
+	if got := never.Next(from); !got.IsZero() {
+		t.Fatalf("Feb 30 = %v", got)
+	}
+}
@@ -0,0 +1,440 @@
+// Package scheduler 延迟任务和周期任务的调度器
+//
+// 9.group/schedgroup 使用的 mdlayher/schedgroup 只能延迟执行一次，12.scheduler/schedule 研究的是 runtime 的调度；
+// Scheduler 是应用层的定时任务调度器：
+//
+//	所有的任务按下一次执行的时间放在一个最小堆中，一个调度 goroutine 只等待堆顶的任务，到期后交给 pool.WorkerPool 执行
+//	RunAt、RunAfter 执行一次；Every 按固定的间隔执行，间隔从计划时间算起，不会累积漂移；Cron 按 5 个字段的 cron 表达式执行
+//	WithJitter 在计划时间上加随机的延迟，避免大量实例在同一时刻执行
+//	调度落后（进程暂停、worker 都在忙）错过了多次执行时，按 MissedRunPolicy 跳过或者补上
+//	Task.Cancel 取消任务；Shutdown 停止调度，等待已经开始和已经排队的执行完成，ctx 结束时取消它们
+//
+// 时间来自 clock.Clock，测试使用 clock.Fake，不需要真的等待
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang/concurrent/clock"
+	"golang/concurrent/future"
+	"golang/concurrent/pool"
+)
+
+// ErrClosed Scheduler 已经 Shutdown
+var ErrClosed = errors.New("scheduler: closed")
+
+// MissedRunPolicy 周期任务错过了多次执行时如何处理
+type MissedRunPolicy int
+
+const (
+	Skip    MissedRunPolicy = iota // 只执行一次，跳过错过的，下一次为现在之后的第一个计划时间
+	CatchUp                        // 每个错过的计划时间都补执行一次
+)
+
+// Schedule 周期任务的计划
+type Schedule interface {
+	// Next 返回 t 之后的下一个计划时间，零值表示不再执行
+	Next(t time.Time) time.Time
+}
+
+// ==========Scheduler==========
+
+type config struct {
+	clock     clock.Clock
+	rnd       *rand.Rand
+	workers   int
+	queueSize int
+	onError   func(*Task, error)
+}
+
+// Option Scheduler 的选项
+type Option func(*config)
+
+// WithClock 使用 c 作为时钟，默认为 clock.Real
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+// WithRand 使用 rnd 计算抖动，默认以当前时间为种子
+func WithRand(rnd *rand.Rand) Option {
+	return func(cfg *config) { cfg.rnd = rnd }
+}
+
+// WithWorkers 执行任务的 worker 数量，默认为 4
+// worker 多于 1 时同一个周期任务的多次执行可能并发
+func WithWorkers(n int) Option {
+	return func(cfg *config) { cfg.workers = n }
+}
+
+// WithQueueSize 等待 worker 的执行队列的容量，默认为 64；队列满时调度 goroutine 阻塞，之后的任务可能错过计划时间
+func WithQueueSize(n int) Option {
+	return func(cfg *config) { cfg.queueSize = n }
+}
+
+// WithErrorHandler 任务返回错误或者 panic（*future.PanicError）时调用 f，默认忽略
+func WithErrorHandler(f func(t *Task, err error)) Option {
+	return func(cfg *config) { cfg.onError = f }
+}
+
+// Scheduler 定时任务调度器
+type Scheduler struct {
+	clock   clock.Clock
+	onError func(*Task, error)
+	pool    *pool.WorkerPool[*Task, struct{}]
+
+	ctx      context.Context // Shutdown 时取消，停止调度 goroutine
+	cancel   context.CancelFunc
+	loopDone chan struct{}
+
+	mu     sync.Mutex
+	timer  clock.Timer // 在堆顶的任务到期时触发，堆顶变化时在持有 mu 时重置
+	rnd    *rand.Rand
+	tasks  taskHeap
+	closed bool
+	// undispatched 已经到期、还没交给 worker 时 Shutdown 打断了调度 goroutine，由 Shutdown 交给 worker
+	undispatched []*Task
+}
+
+// New 创建 Scheduler 并启动调度 goroutine
+func New(opts ...Option) *Scheduler {
+	cfg := config{clock: clock.Real, workers: 4, queueSize: 64}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.rnd == nil {
+		cfg.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	s := &Scheduler{
+		clock:    cfg.clock,
+		onError:  cfg.onError,
+		loopDone: make(chan struct{}),
+		timer:    cfg.clock.NewTimer(time.Hour),
+		rnd:      cfg.rnd,
+	}
+	s.timer.Stop()
+	p, err := pool.NewWorkerPool(s.run, pool.WorkerConfig{
+		Workers:   max(cfg.workers, 1),
+		QueueSize: max(cfg.queueSize, 0),
+		Policy:    pool.Block,
+	})
+	if err != nil {
+		panic(err)
+	}
+	s.pool = p
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.loop()
+	return s
+}
+
+// RunAt 在 at 执行一次 fn，at 已经过去时立即执行
+// fn 的 ctx 在 Shutdown 超时时被取消
+func (s *Scheduler) RunAt(at time.Time, fn func(ctx context.Context) error) (*Task, error) {
+	return s.add(&Task{fn: fn, next: at, base: at})
+}
+
+// RunAfter 在 d 之后执行一次 fn
+func (s *Scheduler) RunAfter(d time.Duration, fn func(ctx context.Context) error) (*Task, error) {
+	return s.RunAt(s.clock.Now().Add(d), fn)
+}
+
+// Every 从现在起每隔 d 执行一次 fn，第一次在 d 之后
+func (s *Scheduler) Every(d time.Duration, fn func(ctx context.Context) error, opts ...TaskOption) (*Task, error) {
+	if d <= 0 {
+		return nil, errors.New("scheduler: non-positive interval for Every")
+	}
+	return s.Schedule(every(d), fn, opts...)
+}
+
+// Cron 按 cron 表达式执行 fn，表达式的格式见 ParseCron，时区为时钟返回的时间的时区
+func (s *Scheduler) Cron(expr string, fn func(ctx context.Context) error, opts ...TaskOption) (*Task, error) {
+	sched, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.Schedule(sched, fn, opts...)
+}
+
+// Schedule 按 sched 周期性地执行 fn
+func (s *Scheduler) Schedule(sched Schedule, fn func(ctx context.Context) error, opts ...TaskOption) (*Task, error) {
+	t := &Task{fn: fn, sched: sched}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.base = sched.Next(s.clock.Now())
+	if t.base.IsZero() {
+		return nil, errors.New("scheduler: schedule never fires")
+	}
+	return s.add(t)
+}
+
+// Shutdown 停止调度，等待已经开始和已经排队的执行完成；ctx 结束时取消它们的 ctx，等它们返回后返回 ctx.Err()
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		for _, t := range s.tasks {
+			t.index = -1
+		}
+		s.tasks = nil
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+	s.cancel()
+	<-s.loopDone
+
+	// 已经到期的任务与已经排队的一样，等它们执行完
+	s.mu.Lock()
+	due := s.undispatched
+	s.undispatched = nil
+	s.mu.Unlock()
+	for _, t := range due {
+		if _, err := s.pool.Submit(ctx, t); err != nil { // ctx 结束
+			s.pool.StopNow()
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.pool.StopWait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.pool.StopNow()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Len 等待执行的任务数
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks)
+}
+
+func (s *Scheduler) add(t *Task) (*Task, error) {
+	t.s = s
+	t.index = -1
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	if t.sched != nil {
+		t.next = t.base.Add(s.jitter(t))
+	}
+	heap.Push(&s.tasks, t)
+	if t.index == 0 {
+		s.resetTimer(s.clock.Now())
+	}
+	return t, nil
+}
+
+// resetTimer 按堆顶的任务重置 timer，需要持有 s.mu
+func (s *Scheduler) resetTimer(now time.Time) {
+	s.timer.Stop()
+	if len(s.tasks) > 0 {
+		s.timer.Reset(max(s.tasks[0].next.Sub(now), 0))
+	}
+}
+
+// loop 调度 goroutine：timer 触发时取出到期的任务交给 worker
+func (s *Scheduler) loop() {
+	defer close(s.loopDone)
+	for {
+		select {
+		case <-s.timer.C():
+		case <-s.ctx.Done():
+			return
+		}
+
+		s.mu.Lock()
+		now := s.clock.Now()
+		var due []*Task
+		for len(s.tasks) > 0 && !s.tasks[0].next.After(now) {
+			t := heap.Pop(&s.tasks).(*Task)
+			due = append(due, t)
+			s.reschedule(t, now)
+		}
+		if !s.closed {
+			s.resetTimer(now)
+		}
+		s.mu.Unlock()
+
+		for i, t := range due { // 队列满时阻塞，timer 在这期间触发时下一轮立即检查
+			if _, err := s.pool.Submit(s.ctx, t); err != nil { // Shutdown
+				s.mu.Lock()
+				s.undispatched = due[i:]
+				s.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// reschedule 周期任务计算下一次执行的时间放回堆中，需要持有 s.mu
+func (s *Scheduler) reschedule(t *Task, now time.Time) {
+	if t.sched == nil {
+		return
+	}
+	base := t.sched.Next(t.base)
+	if t.policy == Skip {
+		for !base.IsZero() && !base.After(now) {
+			base = t.sched.Next(base)
+			t.skipped.Add(1)
+		}
+	}
+	if base.IsZero() {
+		return
+	}
+	t.base = base
+	t.next = base.Add(s.jitter(t))
+	heap.Push(&s.tasks, t)
+}
+
+// jitter 需要持有 s.mu
+func (s *Scheduler) jitter(t *Task) time.Duration {
+	if t.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(s.rnd.Int63n(int64(t.jitter)))
+}
+
+// run worker 执行任务
+func (s *Scheduler) run(ctx context.Context, t *Task) (struct{}, error) {
+	if t.Canceled() {
+		return struct{}{}, nil
+	}
+	err := call(ctx, t.fn)
+	t.runs.Add(1)
+	if err != nil && s.onError != nil {
+		s.onError(t, err)
+	}
+	return struct{}{}, err
+}
+
+func call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &future.PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// ==========Task==========
+
+// TaskOption 周期任务的选项
+type TaskOption func(*Task)
+
+// WithJitter 每次执行的时间在计划时间上加 [0, d) 的随机延迟，不会提前执行
+func WithJitter(d time.Duration) TaskOption {
+	return func(t *Task) { t.jitter = d }
+}
+
+// WithMissedRun 错过多次执行时的策略，默认为 Skip
+func WithMissedRun(p MissedRunPolicy) TaskOption {
+	return func(t *Task) { t.policy = p }
+}
+
+// Task 一个调度的任务
+type Task struct {
+	s      *Scheduler
+	fn     func(ctx context.Context) error
+	sched  Schedule // nil 表示只执行一次
+	jitter time.Duration
+	policy MissedRunPolicy
+
+	// 以下字段由 s.mu 保护
+	base     time.Time // 计划时间
+	next     time.Time // 加上抖动之后实际执行的时间
+	index    int       // 在堆中的位置，-1 表示不在堆中
+	canceled bool
+
+	runs    atomic.Int64
+	skipped atomic.Int64
+}
+
+// Cancel 取消任务，之后不再执行；已经交给 worker 还没开始的执行也会被取消，正在执行的不受影响
+// 返回任务是否还在等待下一次执行
+func (t *Task) Cancel() bool {
+	s := t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.canceled = true
+	if t.index < 0 {
+		return false
+	}
+	top := t.index == 0
+	heap.Remove(&s.tasks, t.index)
+	if top {
+		s.resetTimer(s.clock.Now())
+	}
+	return true
+}
+
+// Canceled 是否已经取消
+func (t *Task) Canceled() bool {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	return t.canceled
+}
+
+// Next 下一次执行的时间，不再执行时为零值
+func (t *Task) Next() time.Time {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if t.index < 0 {
+		return time.Time{}
+	}
+	return t.next
+}
+
+// Runs 已经执行完的次数
+func (t *Task) Runs() int64 { return t.runs.Load() }
+
+// Skipped Skip 策略跳过的执行次数
+func (t *Task) Skipped() int64 { return t.skipped.Load() }
+
+// taskHeap 按 next 排序的最小堆
+type taskHeap []*Task
+
+func (h taskHeap) Len() int           { return len(h) }
+func (h taskHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x any) {
+	t := x.(*Task)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
+
+// every 固定间隔的计划
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
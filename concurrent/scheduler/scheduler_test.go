@@ -0,0 +1,257 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+	"golang/concurrent/future"
+)
+
+func newScheduler(opts ...Option) (*Scheduler, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return New(append([]Option{WithClock(c)}, opts...)...), c
+}
+
+// record 返回一个把执行时的时间发送到 ch 的任务
+func record(c clock.Clock, ch chan<- time.Time) func(context.Context) error {
+	return func(context.Context) error {
+		ch <- c.Now()
+		return nil
+	}
+}
+
+// expect 接收 n 次执行的时间，检查它们相对 start 的偏移
+func expect(t *testing.T, ch <-chan time.Time, start time.Time, offsets ...time.Duration) {
+	t.Helper()
+	for _, want := range offsets {
+		select {
+		case at := <-ch:
+			if got := at.Sub(start); got != want {
+				t.Fatalf("ran at +%v, want +%v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("task did not run at +%v", want)
+		}
+	}
+}
+
+func expectNone(t *testing.T, ch <-chan time.Time) {
+	t.Helper()
+	select {
+	case at := <-ch:
+		t.Fatalf("unexpected run at %v", at)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestRunAtAfter(t *testing.T) {
+	s, c := newScheduler(WithWorkers(1))
+	defer s.Shutdown(context.Background())
+	start := c.Now()
+	ch := make(chan time.Time, 10)
+
+	s.RunAfter(2*time.Second, record(c, ch))
+	s.RunAt(start.Add(time.Second), record(c, ch)) // 新的堆顶
+	cancelled, _ := s.RunAfter(1500*time.Millisecond, record(c, ch))
+	if !cancelled.Cancel() || cancelled.Cancel() {
+		t.Fatal("Cancel")
+	}
+	if s.Len() != 2 {
+		t.Fatalf("Len = %d", s.Len())
+	}
+
+	c.Advance(time.Second)
+	expect(t, ch, start, time.Second)
+	c.Advance(time.Second)
+	expect(t, ch, start, 2*time.Second)
+	c.Advance(time.Hour)
+	expectNone(t, ch)
+
+	s.RunAt(start, record(c, ch)) // 已经过去的时间立即执行
+	c.Advance(0)
+	expect(t, ch, start, time.Hour+2*time.Second)
+}
+
+func TestEvery(t *testing.T) {
+	s, c := newScheduler()
+	defer s.Shutdown(context.Background())
+	start := c.Now()
+	ch := make(chan time.Time, 10)
+
+	task, err := s.Every(time.Minute, record(c, ch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Minute)
+		expect(t, ch, start, time.Duration(i)*time.Minute)
+	}
+	if !task.Next().Equal(start.Add(4 * time.Minute)) {
+		t.Fatalf("Next = %v", task.Next())
+	}
+	task.Cancel()
+	c.Advance(time.Hour)
+	expectNone(t, ch)
+	if !task.Next().IsZero() {
+		t.Fatalf("Next after Cancel = %v", task.Next())
+	}
+}
+
+func TestJitter(t *testing.T) {
+	s, c := newScheduler(WithRand(rand.New(rand.NewSource(1))))
+	defer s.Shutdown(context.Background())
+	start := c.Now()
+
+	task, _ := s.Every(time.Minute, func(context.Context) error { return nil }, WithJitter(10*time.Second))
+	for i := 1; i <= 20; i++ {
+		base := start.Add(time.Duration(i) * time.Minute)
+		next := task.Next()
+		if next.Before(base) || !next.Before(base.Add(10*time.Second)) {
+			t.Fatalf("run %d at %v, want within [%v, +10s)", i, next.Sub(start), base.Sub(start))
+		}
+		c.Advance(next.Sub(c.Now()))
+		for task.Runs() < int64(i) { // 等待执行完，之后的计划不受抖动的累积影响
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestMissedRuns(t *testing.T) {
+	s, c := newScheduler(WithWorkers(1))
+	defer s.Shutdown(context.Background())
+	start := c.Now()
+	skipCh := make(chan time.Time, 10)
+	catchCh := make(chan time.Time, 10)
+
+	skip, _ := s.Every(time.Minute, record(c, skipCh))
+	catch, _ := s.Every(time.Minute, record(c, catchCh), WithMissedRun(CatchUp))
+
+	c.Advance(3*time.Minute + 30*time.Second) // 错过了 1、2、3 分钟
+	expect(t, skipCh, start, 3*time.Minute+30*time.Second)
+	expectNone(t, skipCh)
+	expect(t, catchCh, start, 3*time.Minute+30*time.Second, 3*time.Minute+30*time.Second, 3*time.Minute+30*time.Second)
+
+	if skip.Skipped() != 2 || !skip.Next().Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("skipped = %d, next = %v", skip.Skipped(), skip.Next())
+	}
+	if !catch.Next().Equal(start.Add(4 * time.Minute)) {
+		t.Fatalf("catch-up next = %v", catch.Next())
+	}
+}
+
+func TestCronTask(t *testing.T) {
+	s, c := newScheduler()
+	defer s.Shutdown(context.Background())
+	start := c.Now() // 2024-01-01 00:00，星期一
+	ch := make(chan time.Time, 10)
+
+	if _, err := s.Cron("*/15 9 * * mon-fri", record(c, ch)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Cron("61 * * * *", record(c, ch)); !errors.Is(err, ErrInvalidCron) {
+		t.Fatalf("invalid expression err = %v", err)
+	}
+	c.Advance(9*time.Hour + 30*time.Minute) // 9:00 到期时调度 goroutine 看到的已经是 9:30
+	expect(t, ch, start, 9*time.Hour+30*time.Minute)
+	expectNone(t, ch) // 错过的 9:15、9:30 被跳过
+	c.Advance(15 * time.Minute)
+	expect(t, ch, start, 9*time.Hour+45*time.Minute)
+}
+
+func TestErrorHandler(t *testing.T) {
+	errs := make(chan error, 2)
+	s, c := newScheduler(WithErrorHandler(func(_ *Task, err error) { errs <- err }))
+	defer s.Shutdown(context.Background())
+
+	errFail := errors.New("fail")
+	s.RunAfter(time.Second, func(context.Context) error { return errFail })
+	s.RunAfter(time.Second, func(context.Context) error { panic("boom") })
+	c.Advance(time.Second)
+	var failed, panicked bool
+	for i := 0; i < 2; i++ {
+		err := <-errs
+		var pe *future.PanicError
+		failed = failed || err == errFail
+		panicked = panicked || errors.As(err, &pe)
+	}
+	if !failed || !panicked {
+		t.Fatalf("failed = %v, panicked = %v", failed, panicked)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s, c := newScheduler(WithWorkers(1))
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	s.RunAfter(time.Second, func(ctx context.Context) error {
+		close(started)
+		time.Sleep(10 * time.Millisecond)
+		close(finished)
+		return nil
+	})
+	pending, _ := s.RunAfter(time.Hour, func(context.Context) error { return nil })
+	c.Advance(time.Second)
+	<-started
+
+	if err := s.Shutdown(context.Background()); err != nil { // 等待正在执行的任务
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Shutdown returned before the running task finished")
+	}
+	if !pending.Next().IsZero() || s.Len() != 0 {
+		t.Fatal("pending task still scheduled after Shutdown")
+	}
+	if _, err := s.RunAfter(time.Second, func(context.Context) error { return nil }); err != ErrClosed {
+		t.Fatalf("RunAfter after Shutdown = %v", err)
+	}
+
+	// ctx 超时时取消正在执行的任务
+	s, c = newScheduler(WithWorkers(1))
+	started = make(chan struct{})
+	s.RunAfter(time.Second, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.Advance(time.Second)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v", err)
+	}
+}
+
+func TestShutdownDueTasks(t *testing.T) {
+	// 队列已满时调度 goroutine 阻塞在 Submit 上，Shutdown 打断它之后仍然执行已经到期的任务
+	s, c := newScheduler(WithWorkers(1), WithQueueSize(0))
+	release := make(chan struct{})
+	first, _ := s.RunAfter(time.Second, func(context.Context) error {
+		<-release
+		return nil
+	})
+	second, _ := s.RunAfter(time.Second, func(context.Context) error { return nil })
+	c.Advance(time.Second)
+	for s.pool.Metrics().Blocked == 0 {
+		runtime.Gosched()
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Shutdown(context.Background()) }()
+	<-s.loopDone // 调度 goroutine 退出之后 worker 才空闲
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if first.Runs() != 1 || second.Runs() != 1 {
+		t.Fatalf("runs = %d, %d", first.Runs(), second.Runs())
+	}
+}
@@ -0,0 +1,239 @@
+// Package singleflightx 泛型的 SingleFlight，source/17.singleflight.go 的扩展版本
+//
+// 与 x/sync/singleflight 相比：
+//
+//	Group[K, V]：key 和结果都有类型，不需要类型断言
+//	Do 接收 ctx：fn 在单独的 goroutine 中执行，某个调用者的 ctx 结束时只有它自己返回，其它调用者继续等待；
+//	    所有调用者都离开时才取消传给 fn 的 ctx
+//	WithTTL、WithErrorTTL：fn 返回之后，结果在一小段时间内继续返回给同一个 key 的调用者，错误也可以缓存（负缓存），
+//	    缓存过期的瞬间不会有大量的请求同时打到后端（缓存击穿）
+//	Do 返回这次执行服务了多少个调用者，包括合并的请求和命中缓存的请求
+//	fn panic 时转换为 *future.PanicError 返回给所有的调用者，而不是让进程崩溃
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"golang/concurrent/clock"
+	"golang/concurrent/future"
+)
+
+// ErrGoexit fn 调用了 runtime.Goexit
+var ErrGoexit = errors.New("singleflight: runtime.Goexit was called")
+
+type config struct {
+	clock  clock.Clock
+	ttl    time.Duration
+	errTTL time.Duration
+}
+
+// Option Group 的选项
+type Option func(*config)
+
+// WithTTL fn 成功返回的结果缓存 d，默认不缓存
+func WithTTL(d time.Duration) Option {
+	return func(cfg *config) { cfg.ttl = d }
+}
+
+// WithErrorTTL fn 返回的错误缓存 d（负缓存），默认不缓存，后端故障时避免每个请求都去重试
+func WithErrorTTL(d time.Duration) Option {
+	return func(cfg *config) { cfg.errTTL = d }
+}
+
+// WithClock 使用 c 计算缓存的过期时间，默认为 clock.Real
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+// Result DoChan 的结果
+type Result[V any] struct {
+	Val     V
+	Err     error
+	Callers int // 这次执行服务的调用者的数量
+}
+
+// call 一次 fn 的执行
+type call[V any] struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	// 以下字段由 Group.mu 保护
+	waiters int       // 还在等待结果的调用者
+	callers int       // 服务的调用者，包括已经拿到结果的和命中缓存的
+	expires time.Time // 缓存的过期时间
+
+	// fn 返回之后只读
+	val V
+	err error
+}
+
+// Group 按 key 合并并发的请求
+//
+// 零值可用：不缓存结果，使用 clock.Real
+type Group[K comparable, V any] struct {
+	cfg config
+
+	mu        sync.Mutex
+	calls     map[K]*call[V] // 正在执行的
+	cache     map[K]*call[V] // 已经返回、还没有过期的
+	lastSweep time.Time
+}
+
+// New 创建一个 Group
+func New[K comparable, V any](opts ...Option) *Group[K, V] {
+	g := &Group[K, V]{}
+	for _, opt := range opts {
+		opt(&g.cfg)
+	}
+	return g
+}
+
+// Do 执行 fn 并返回它的结果，同一个 key 同时只有一个 fn 在执行，其它的调用者等待它的结果；
+// 缓存没有过期时直接返回缓存的结果
+//
+// ctx 结束时 Do 返回 ctx.Err()，fn 继续为其它调用者执行；传给 fn 的 ctx 带有第一个调用者 ctx 的值，
+// 在所有的调用者都离开时被取消
+// callers 为这次执行到现在为止服务的调用者的数量，大于 1 表示结果是共享的
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (v V, err error, callers int) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+		g.cache = make(map[K]*call[V])
+	}
+	now := g.now()
+	if c, ok := g.cache[key]; ok {
+		if now.Before(c.expires) { // 命中缓存
+			c.callers++
+			callers = c.callers
+			g.mu.Unlock()
+			return c.val, c.err, callers
+		}
+		delete(g.cache, key)
+	}
+	g.sweep(now)
+
+	c, ok := g.calls[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call[V]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go g.run(fctx, key, c, fn)
+	}
+	c.waiters++
+	c.callers++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		if g.detach(key, c) {
+			var zero V
+			return zero, ctx.Err(), 0
+		}
+	}
+	g.mu.Lock()
+	callers = c.callers
+	g.mu.Unlock()
+	return c.val, c.err, callers
+}
+
+// DoChan 与 Do 相同，但是返回一个 chan，fn 返回或者 ctx 结束时从它接收结果
+func (g *Group[K, V]) DoChan(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	go func() {
+		v, err, callers := g.Do(ctx, key, fn)
+		ch <- Result[V]{Val: v, Err: err, Callers: callers}
+	}()
+	return ch
+}
+
+// Forget 忘记 key 正在执行的请求和缓存的结果，之后的调用会重新执行 fn
+// 正在等待的调用者依然会得到原来那次执行的结果
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	delete(g.cache, key)
+	g.mu.Unlock()
+}
+
+// detach 调用者的 ctx 结束，离开这次执行；最后一个调用者离开时取消 fn
+// 返回 false 表示 fn 已经返回，调用者应该使用它的结果
+func (g *Group[K, V]) detach(key K, c *call[V]) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	c.waiters--
+	c.callers--
+	if c.waiters == 0 {
+		c.cancel()
+		if g.calls[key] == c { // 之后的调用重新执行，不等待一个已经取消的 fn
+			delete(g.calls, key)
+		}
+	}
+	return true
+}
+
+// run 在单独的 goroutine 中执行 fn
+func (g *Group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(ctx context.Context) (V, error)) {
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			if r := recover(); r != nil {
+				c.err = &future.PanicError{Value: r, Stack: debug.Stack()}
+			} else {
+				c.err = ErrGoexit
+			}
+		}
+		g.finish(key, c)
+	}()
+	c.val, c.err = fn(ctx)
+	normalReturn = true
+}
+
+// finish fn 返回，通知等待的调用者，按 TTL 缓存结果
+func (g *Group[K, V]) finish(key K, c *call[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c.cancel()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+		ttl := g.cfg.ttl
+		if c.err != nil {
+			ttl = g.cfg.errTTL
+		}
+		if ttl > 0 { // 被 Forget 或者所有调用者都离开的执行不缓存
+			c.expires = g.now().Add(ttl)
+			g.cache[key] = c
+		}
+	}
+	close(c.done)
+}
+
+// sweep 删除过期的缓存，每个 TTL 最多扫描一次，需要持有 g.mu
+func (g *Group[K, V]) sweep(now time.Time) {
+	interval := max(g.cfg.ttl, g.cfg.errTTL)
+	if interval <= 0 || now.Sub(g.lastSweep) < interval {
+		return
+	}
+	for key, c := range g.cache {
+		if !now.Before(c.expires) {
+			delete(g.cache, key)
+		}
+	}
+	g.lastSweep = now
+}
+
+func (g *Group[K, V]) now() time.Time {
+	if g.cfg.clock == nil {
+		return clock.Real.Now()
+	}
+	return g.cfg.clock.Now()
+}
@@ -0,0 +1,172 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+	"golang/concurrent/future"
+)
+
+// blocking 返回一个阻塞到 release 关闭的 fn，每次执行时 calls 加一
+func blocking(calls *atomic.Int32, release <-chan struct{}, v string) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+			return v, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// waitCallers 等到 key 正在执行的请求有 n 个调用者
+func waitCallers[K comparable, V any](g *Group[K, V], key K, n int) {
+	for {
+		g.mu.Lock()
+		c := g.calls[key]
+		ok := c != nil && c.waiters == n
+		g.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDo(t *testing.T) {
+	var g Group[string, string] // 零值可用
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err, callers := g.Do(context.Background(), "k", blocking(&calls, release, "v"))
+			if v != "v" || err != nil {
+				t.Errorf("Do = %q, %v", v, err)
+			}
+			results[i] = callers
+		}(i)
+	}
+	waitCallers(&g, "k", n)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("fn called %d times", calls.Load())
+	}
+	for _, callers := range results {
+		if callers != n {
+			t.Fatalf("callers = %v", results)
+		}
+	}
+
+	// 没有缓存，返回之后重新执行
+	if _, _, callers := g.Do(context.Background(), "k", blocking(&calls, release, "v")); callers != 1 || calls.Load() != 2 {
+		t.Fatalf("callers = %d, calls = %d", callers, calls.Load())
+	}
+}
+
+func TestDetach(t *testing.T) {
+	var g Group[string, string]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	detached := make(chan error)
+	go func() {
+		_, err, _ := g.Do(ctx, "k", blocking(&calls, release, "v"))
+		detached <- err
+	}()
+	waitCallers(&g, "k", 1)
+	stayed := g.DoChan(context.Background(), "k", blocking(&calls, release, "other"))
+	waitCallers(&g, "k", 2)
+
+	cancel() // 只有第一个调用者离开，fn 继续执行
+	if err := <-detached; !errors.Is(err, context.Canceled) {
+		t.Fatalf("detached caller err = %v", err)
+	}
+	close(release)
+	if r := <-stayed; r.Val != "v" || r.Err != nil || r.Callers != 1 {
+		t.Fatalf("remaining caller = %+v", r)
+	}
+
+	// 所有调用者都离开时取消 fn，之后的调用重新执行
+	var fnErr atomic.Value
+	ctx, cancel = context.WithCancel(context.Background())
+	fnDone := make(chan struct{})
+	go g.Do(ctx, "x", func(ctx context.Context) (string, error) {
+		defer close(fnDone)
+		<-ctx.Done()
+		fnErr.Store(ctx.Err())
+		return "", ctx.Err()
+	})
+	waitCallers(&g, "x", 1)
+	cancel()
+	<-fnDone
+	if fnErr.Load() != context.Canceled {
+		t.Fatalf("fn ctx err = %v", fnErr.Load())
+	}
+	if v, err, _ := g.Do(context.Background(), "x", func(context.Context) (string, error) { return "new", nil }); v != "new" || err != nil {
+		t.Fatalf("Do after abandon = %q, %v", v, err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	g := New[int, int](WithTTL(time.Second), WithErrorTTL(100*time.Millisecond), WithClock(c))
+	var calls atomic.Int32
+	ok := func(context.Context) (int, error) { return int(calls.Add(1)), nil }
+
+	g.Do(context.Background(), 1, ok)
+	c.Advance(999 * time.Millisecond)
+	if v, _, callers := g.Do(context.Background(), 1, ok); v != 1 || callers != 2 {
+		t.Fatalf("cached Do = %d, callers %d", v, callers)
+	}
+	c.Advance(time.Millisecond)
+	if v, _, callers := g.Do(context.Background(), 1, ok); v != 2 || callers != 1 {
+		t.Fatalf("expired Do = %d, callers %d", v, callers)
+	}
+
+	// 负缓存
+	errDown := errors.New("backend down")
+	fail := func(context.Context) (int, error) { calls.Add(1); return 0, errDown }
+	g.Do(context.Background(), 2, fail)
+	if _, err, _ := g.Do(context.Background(), 2, ok); err != errDown || calls.Load() != 3 {
+		t.Fatalf("negative cache err = %v, calls = %d", err, calls.Load())
+	}
+	c.Advance(100 * time.Millisecond)
+	if _, err, _ := g.Do(context.Background(), 2, ok); err != nil {
+		t.Fatalf("after error TTL err = %v", err)
+	}
+
+	g.Forget(2)
+	if v, _, _ := g.Do(context.Background(), 2, ok); v != 5 {
+		t.Fatalf("Do after Forget = %d", v)
+	}
+
+	c.Advance(time.Hour) // 过期的缓存被扫描删除
+	g.Do(context.Background(), 3, ok)
+	if len(g.cache) != 1 {
+		t.Fatalf("cache size = %d", len(g.cache))
+	}
+}
+
+func TestPanic(t *testing.T) {
+	var g Group[string, int]
+	errBoom := errors.New("boom")
+	_, err, _ := g.Do(context.Background(), "k", func(context.Context) (int, error) { panic(errBoom) })
+	var pe *future.PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -1,7 +1,10 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/petermattis/goid"
 	"golang/concurrent"
 	"math"
 	"math/rand"
@@ -120,3 +123,159 @@ func foo(mu sync.Mutex) {
 	defer mu.Unlock()
 	fmt.Println("in foo")
 }
+
+func TestReentrantMutex(t *testing.T) {
+	var mu concurrent.ReentrantMutex
+	var l sync.Locker = &mu
+	fooReentrantLock(l) // 重入不会死锁
+
+	mu.Lock()
+	if !mu.TryLock() { // 持有者 TryLock 重入
+		t.Fatal("owner TryLock failed")
+	}
+	done := make(chan bool)
+	go func() { done <- mu.TryLock() }()
+	if <-done {
+		t.Fatal("TryLock succeeded while held by another goroutine")
+	}
+	mu.Unlock()
+	mu.Unlock()
+	go func() {
+		ok := mu.TryLock()
+		if ok {
+			mu.Unlock()
+		}
+		done <- ok
+	}()
+	if !<-done {
+		t.Fatal("TryLock failed on unlocked mutex")
+	}
+}
+
+func TestReentrantMutexLockContext(t *testing.T) {
+	var mu concurrent.ReentrantMutex
+	mu.Lock()
+	defer mu.Unlock()
+	errc := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		errc <- mu.LockContext(ctx)
+	}()
+	err := <-errc
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	var le *concurrent.LockError
+	if !errors.As(err, &le) || le.Owner != goid.Get() || !strings.Contains(le.Stack, "TestReentrantMutexLockContext") {
+		t.Fatalf("missing owner diagnostics: %v", err)
+	}
+}
+
+func TestReentrantMutexMisuse(t *testing.T) {
+	var mu concurrent.ReentrantMutex
+	mu.Lock()
+	defer mu.Unlock()
+	done := make(chan any)
+	go func() {
+		defer func() { done <- recover() }()
+		mu.Unlock() // 非持有者释放锁
+	}()
+	le, ok := (<-done).(*concurrent.LockError)
+	if !ok || le.Owner != goid.Get() || le.Stack == "" {
+		t.Fatalf("got %v, want *LockError with owner diagnostics", le)
+	}
+	t.Log(le)
+
+	var unlocked concurrent.ReentrantMutex
+	defer func() {
+		if _, ok := recover().(*concurrent.LockError); !ok {
+			t.Fatal("expected *LockError panic")
+		}
+	}()
+	unlocked.Unlock()
+}
+
+func TestTokenRecursiveMutexMisuse(t *testing.T) {
+	var mu concurrent.TokenRecursiveMutex
+	mu.Lock(1)
+	mu.Lock(1)
+	mu.Unlock(1)
+	defer func() {
+		le, ok := recover().(*concurrent.LockError)
+		if !ok || le.Owner != 1 || le.Goroutine != 2 {
+			t.Fatalf("got %v, want *LockError", le)
+		}
+		mu.Unlock(1)
+	}()
+	mu.Unlock(2)
+}
+
+func TestReentrantRWMutex(t *testing.T) {
+	var rw concurrent.ReentrantRWMutex
+	rw.Lock()
+	rw.Lock()
+	rw.RLock() // 持有写锁时可以加读锁
+	rw.RUnlock()
+	rw.Unlock()
+	rw.Unlock()
+
+	// 递归读锁不会被等待的 writer 阻塞（RWMutexCircleWaite）
+	var wg sync.WaitGroup
+	wg.Add(1)
+	rw.RLock()
+	go func() {
+		defer wg.Done()
+		rw.Lock()
+		rw.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	jumped := make(chan bool)
+	go func() { // 新来的 reader 需要等待 writer
+		ok := rw.TryRLock()
+		if ok {
+			rw.RUnlock()
+		}
+		jumped <- ok
+	}()
+	if <-jumped {
+		t.Error("new reader jumped ahead of waiting writer")
+	}
+	rw.RLock()
+	rw.RUnlock()
+
+	// 锁升级会死锁
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var le *concurrent.LockError
+	if err := rw.LockContext(ctx); !errors.As(err, &le) || len(le.Readers) != 1 {
+		t.Fatalf("got %v, want upgrade error", err)
+	}
+	rw.RUnlock()
+	wg.Wait()
+}
+
+func TestReentrantRWMutexContext(t *testing.T) {
+	var rw concurrent.ReentrantRWMutex
+	rw.RLock()
+	errc := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		errc <- rw.LockContext(ctx)
+	}()
+	if err := <-errc; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	go func() { // 超时的 writer 不能继续挡住 reader
+		ok := rw.TryRLock()
+		if ok {
+			rw.RUnlock()
+		}
+		errc <- fmt.Errorf("%t", ok)
+	}()
+	if err := <-errc; err.Error() != "true" {
+		t.Fatal("canceled writer still blocks readers")
+	}
+	rw.RUnlock()
+}
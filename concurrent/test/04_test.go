@@ -3,6 +3,8 @@ package test
 import (
 	"golang/concurrent"
 	"math/rand"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -65,3 +67,67 @@ func TestTryLock(t *testing.T) {
 	// 没有获取到
 	t.Log("can't get the lock")
 }
+
+func TestStatsMutex(t *testing.T) {
+	var mu concurrent.StatsMutex
+	var l sync.Locker = &mu
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				l.Lock()
+				time.Sleep(100 * time.Microsecond)
+				l.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := mu.Stats()
+	if s.Acquisitions != 100 {
+		t.Fatalf("got %d acquisitions, want 100", s.Acquisitions)
+	}
+	var holds int64
+	for _, n := range s.HoldHistogram {
+		holds += n
+	}
+	if holds != 100 || s.HoldHistogram[0] != 0 { // 每次持有至少 100µs
+		t.Fatalf("bad hold histogram: %v", s.HoldHistogram)
+	}
+	// 竞争的程度取决于调度，只检查不变式：第一次获取不会有竞争，进入饥饿模式之前一定有竞争
+	if s.Contended > s.Acquisitions-1 || s.StarvationEntries > s.Contended || s.MaxWait > s.TotalWait || s.MaxHold < 100*time.Microsecond {
+		t.Fatalf("inconsistent stats: %+v", s)
+	}
+	if s.Waiters != 0 || s.Locked || s.Starving {
+		t.Fatalf("bad state after all unlocked: %+v", s)
+	}
+	t.Logf("%+v avg wait %v avg hold %v", s, s.AvgWait(), s.AvgHold())
+}
+
+func TestStatsMutexState(t *testing.T) {
+	var mu concurrent.StatsMutex
+	var wg sync.WaitGroup
+	mu.Lock()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			mu.Unlock()
+		}()
+	}
+	for mu.WaiterCount() < 3 {
+		runtime.Gosched()
+	}
+	if !mu.IsLocked() || mu.TryLock() {
+		t.Fatalf("locked %t", mu.IsLocked())
+	}
+	time.Sleep(2 * time.Millisecond) // 每个等待者都等待超过 1ms
+	mu.Unlock()
+	wg.Wait()
+	if s := mu.Stats(); s.Acquisitions != 4 || s.Contended != 3 || s.StarvationEntries != 1 || s.Starving {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
@@ -1,8 +1,11 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"golang/concurrent"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -50,3 +53,171 @@ func TestRUnlock(t *testing.T) {
 	}()
 	wg.Wait()
 }
+
+// waitQueued 等待刚启动的 goroutine 阻塞在锁上
+func waitQueued() { time.Sleep(10 * time.Millisecond) }
+
+func TestFairRWMutexFIFO(t *testing.T) {
+	var rw concurrent.FairRWMutex
+	rw.Lock()
+
+	// 按到达的顺序获取锁：w1、r1 r2、w2、r3
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	record := func(s string) {
+		mu.Lock()
+		order = append(order, s)
+		mu.Unlock()
+	}
+	for _, name := range []string{"w1", "r1", "r2", "w2", "r3"} {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if name[0] == 'w' {
+				rw.Lock()
+				record(name)
+				time.Sleep(time.Millisecond)
+				rw.Unlock()
+			} else {
+				rw.RLock()
+				record(name)
+				time.Sleep(time.Millisecond)
+				rw.RUnlock()
+			}
+		}()
+		waitQueued()
+	}
+	rw.Unlock()
+	wg.Wait()
+
+	got := order[0] + " " + order[1] + order[2] + " " + order[3] + " " + order[4]
+	if got != "w1 r1r2 w2 r3" && got != "w1 r2r1 w2 r3" {
+		t.Fatalf("order = %v", order)
+	}
+}
+
+func TestFairRWMutexWriterPriority(t *testing.T) {
+	var rw concurrent.FairRWMutex
+	rw.RLock()
+	go func() {
+		rw.Lock() // 等待第一个 reader
+		rw.Unlock()
+	}()
+	waitQueued()
+	// writer 在等待，新的 reader 不能插队
+	if rw.TryRLock() {
+		t.Fatal("new reader jumped ahead of waiting writer")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rw.RLockContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RLockContext = %v", err)
+	}
+	rw.RUnlock()
+	rw.RLock() // writer 结束之后可以获取
+	rw.RUnlock()
+}
+
+func TestFairMutexLockContext(t *testing.T) {
+	var m concurrent.FairMutex
+	m.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.LockContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LockContext = %v", err)
+	}
+	if m.TryLock() {
+		t.Fatal("TryLock succeeded while locked")
+	}
+
+	// 取消的等待者从队列中删除，不影响后面的等待者
+	var rw concurrent.FairRWMutex
+	rw.RLock()
+	wctx, wcancel := context.WithCancel(context.Background())
+	werr := make(chan error)
+	go func() { werr <- rw.LockContext(wctx) }()
+	waitQueued()
+	rlocked := make(chan struct{})
+	go func() {
+		rw.RLock() // 排在 writer 后面
+		close(rlocked)
+	}()
+	waitQueued()
+	wcancel()
+	if err := <-werr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("LockContext = %v", err)
+	}
+	<-rlocked // 挡在前面的 writer 取消后，reader 被唤醒
+
+	m.Unlock()
+	if err := m.LockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.Unlock()
+}
+
+func TestFairRWMutexStress(t *testing.T) {
+	var (
+		rw      concurrent.FairRWMutex
+		writers atomic.Int32
+		readers atomic.Int32
+		value   int
+		wg      sync.WaitGroup
+	)
+	check := func() {
+		if w, r := writers.Load(), readers.Load(); w > 1 || (w == 1 && r > 0) {
+			t.Errorf("writers %d, readers %d", w, r)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(j%3)*time.Microsecond)
+				if rw.LockContext(ctx) == nil {
+					writers.Add(1)
+					check()
+					value++
+					writers.Add(-1)
+					rw.Unlock()
+				}
+				cancel()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				rw.RLock()
+				readers.Add(1)
+				check()
+				_ = value
+				readers.Add(-1)
+				rw.RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if !rw.TryLock() {
+		t.Fatal("lock leaked")
+	}
+}
+
+func BenchmarkFairMutex(b *testing.B) {
+	bench := func(b *testing.B, l sync.Locker) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				l.Lock()
+				l.Unlock()
+			}
+		})
+	}
+	b.Run("sync.Mutex", func(b *testing.B) { bench(b, &sync.Mutex{}) })
+	b.Run("FairMutex", func(b *testing.B) { bench(b, &concurrent.FairMutex{}) })
+	b.Run("sync.RWMutex.RLock", func(b *testing.B) { bench(b, (&sync.RWMutex{}).RLocker()) })
+	b.Run("FairRWMutex.RLock", func(b *testing.B) { bench(b, (&concurrent.FairRWMutex{}).RLocker()) })
+}
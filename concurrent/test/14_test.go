@@ -1,8 +1,11 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"golang/concurrent"
+	"golang/concurrent/chanx"
+	"golang/concurrent/clock"
 	"testing"
 	"time"
 )
@@ -65,6 +68,26 @@ func TestOrDone(t *testing.T) {
 func TestChannelMutexDemo(t *testing.T) {
 	concurrent.ChannelMutexDemo()
 }
+func TestChannelMutexLockTimeout(t *testing.T) {
+	c := clock.NewFake(time.Now())
+	m := concurrent.NewMutex(concurrent.WithMutexClock(c))
+	m.Lock()
+
+	locked := make(chan bool)
+	go func() { locked <- m.LockTimeout(time.Minute) }()
+	c.BlockUntil(1) // LockTimeout 已经开始计时
+	c.Advance(time.Minute)
+	if <-locked {
+		t.Fatal("LockTimeout succeeded while locked")
+	}
+
+	go func() { locked <- m.LockTimeout(time.Minute) }()
+	c.BlockUntil(1)
+	m.Unlock()
+	if !<-locked {
+		t.Fatal("LockTimeout failed after Unlock")
+	}
+}
 func TestChannelReflectSelect(t *testing.T) {
 	concurrent.ChannelReflectSelect()
 }
@@ -83,3 +106,59 @@ func TestFanOut(t *testing.T) {
 	//v = <-ch
 	//fmt.Println(v)
 }
+
+// BenchmarkFanIn 比较扇入 n 个 channel 的几种实现，每次操作合并一个元素
+func BenchmarkFanIn(b *testing.B) {
+	// feed 把 b.N 个元素轮流发送到 n 个 channel，发送完后关闭它们
+	feed := func(b *testing.B, n int) []chan any {
+		chs := make([]chan any, n)
+		for i := range chs {
+			chs[i] = make(chan any, 16)
+		}
+		go func() {
+			for i := 0; i < b.N; i++ {
+				chs[i%n] <- i
+			}
+			for _, ch := range chs {
+				close(ch)
+			}
+		}()
+		return chs
+	}
+	ro := func(chs []chan any) []<-chan any {
+		ins := make([]<-chan any, len(chs))
+		for i, ch := range chs {
+			ins[i] = ch
+		}
+		return ins
+	}
+	drain := func(out <-chan any) {
+		for range out {
+		}
+	}
+	for _, n := range []int{8, 64, 1024} {
+		b.Run(fmt.Sprintf("reflect/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			drain(concurrent.FanInReflect(ro(feed(b, n))...))
+		})
+		b.Run(fmt.Sprintf("recursion/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			drain(concurrent.FanInRec(ro(feed(b, n))...))
+		})
+		b.Run(fmt.Sprintf("chanx.Merge/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			drain(chanx.Merge(context.Background(), ro(feed(b, n))...))
+		})
+		b.Run(fmt.Sprintf("chanx.SelectAny/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			chs := feed(b, n)
+			s := chanx.NewSelectAny(ro(chs)...)
+			defer s.Close()
+			for closed := 0; closed < n; {
+				if _, _, ok := s.Recv(context.Background()); !ok {
+					closed++
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package test
 
 import (
+	"context"
+	"fmt"
+	"golang.org/x/sync/semaphore"
 	"golang/concurrent"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestStructVal(t *testing.T) {
@@ -12,3 +20,301 @@ func TestStructVal(t *testing.T) {
 func TestSemaWorkerPool(t *testing.T) {
 	concurrent.SemaWorkerPool()
 }
+
+func TestAtomicSemaphoreStress(t *testing.T) {
+	const size = 10
+	var (
+		s     = concurrent.NewAtomicSemaphore(size)
+		inUse atomic.Int64
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				n := int64(i%size + 1)
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rand.Intn(100))*time.Microsecond)
+				if err := s.Acquire(ctx, n); err != nil { // 取消的请求不能占用资源
+					cancel()
+					continue
+				}
+				cancel()
+				if v := inUse.Add(n); v > size {
+					t.Errorf("in use %d > size %d", v, size)
+				}
+				runtime.Gosched()
+				inUse.Add(-n)
+				s.Release(n)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if !s.TryAcquire(size) { // 没有丢失资源
+		t.Fatal("resources leaked")
+	}
+}
+
+func TestAtomicSemaphoreFIFO(t *testing.T) {
+	s := concurrent.NewAtomicSemaphore(10)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	big := make(chan struct{})
+	go func() {
+		_ = s.Acquire(ctx, 10)
+		close(big)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if s.TryAcquire(1) { // 有等待者时，后来者不能插队
+		t.Fatal("TryAcquire jumped the queue")
+	}
+	small := make(chan struct{})
+	go func() {
+		_ = s.Acquire(ctx, 1)
+		close(small)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.Release(5)
+	select {
+	case <-small:
+		t.Fatal("small waiter served before big waiter")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.Release(5)
+	<-big
+	s.Release(10)
+	<-small
+	s.Release(1)
+}
+
+func TestAtomicSemaphoreCancel(t *testing.T) {
+	s := concurrent.NewAtomicSemaphore(2)
+	if err := s.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Acquire(ctx, 2) }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	// 取消的队首等待者不能挡住后面的请求
+	if err := s.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	s.Release(2)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 3); err != context.DeadlineExceeded { // 超过最大资源数
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAtomicSemaphoreOverRelease(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	concurrent.NewAtomicSemaphore(1).Release(1)
+}
+
+func benchmarkSemaphore(b *testing.B, acquire func(context.Context, int64) error, release func(int64)) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = acquire(ctx, 1)
+			release(1)
+		}
+	})
+}
+
+// BenchmarkAtomicSemaphore 对比互斥锁实现的 Weighted
+// concurrent/source 包引用了 runtime 内部包无法编译，这里直接使用同一份代码的 x/sync 版本
+func BenchmarkAtomicSemaphore(b *testing.B) {
+	for _, size := range []int64{1, 4, 64} {
+		b.Run(fmt.Sprintf("atomic-%d", size), func(b *testing.B) {
+			s := concurrent.NewAtomicSemaphore(size)
+			benchmarkSemaphore(b, s.Acquire, s.Release)
+		})
+		b.Run(fmt.Sprintf("weighted-%d", size), func(b *testing.B) {
+			s := semaphore.NewWeighted(size)
+			benchmarkSemaphore(b, s.Acquire, s.Release)
+		})
+	}
+}
+
+func TestPrioritySemaphoreOrder(t *testing.T) {
+	s := concurrent.NewPrioritySemaphore(1, 0)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for _, p := range []int{1, 3, 2} { // 批处理任务先到，交互请求后到
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			_ = s.Acquire(ctx, 1, p)
+			mu.Lock()
+			order = append(order, p)
+			mu.Unlock()
+			s.Release(1)
+		}(p)
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Release(1)
+	wg.Wait()
+	if order[0] != 3 || order[1] != 2 || order[2] != 1 {
+		t.Fatalf("got order %v, want [3 2 1]", order)
+	}
+}
+
+func TestPrioritySemaphoreAging(t *testing.T) {
+	s := concurrent.NewPrioritySemaphore(1, time.Millisecond)
+	ctx := context.Background()
+	_ = s.Acquire(ctx, 1, 0)
+	got := make(chan int, 2)
+	go func() {
+		_ = s.Acquire(ctx, 1, 0)
+		got <- 0
+		s.Release(1)
+	}()
+	time.Sleep(50 * time.Millisecond) // 等待 50 个 aging 周期，优先级约提升到 50
+	go func() {
+		_ = s.Acquire(ctx, 1, 10)
+		got <- 10
+		s.Release(1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.Release(1)
+	if p := <-got; p != 0 {
+		t.Fatalf("got priority %d first, want the aged waiter", p)
+	}
+	<-got
+}
+
+func TestPrioritySemaphoreCancel(t *testing.T) {
+	s := concurrent.NewPrioritySemaphore(2, 0)
+	_ = s.Acquire(context.Background(), 1, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 2, 5); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !s.TryAcquire(1) { // 取消的等待者不再挡住后面的请求
+		t.Fatal("canceled waiter still blocks the semaphore")
+	}
+}
+
+func TestPriorityMutexInheritance(t *testing.T) {
+	var a, b concurrent.PriorityMutex
+	aLocked, bLocked := make(chan struct{}), make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() { // 低优先级任务持有 a
+		defer wg.Done()
+		a.Lock(1)
+		close(aLocked)
+		<-release
+		a.Unlock()
+	}()
+	<-aLocked
+	go func() { // 中优先级任务持有 b，等待 a
+		defer wg.Done()
+		b.Lock(2)
+		close(bLocked)
+		a.Lock(2)
+		a.Unlock()
+		b.Unlock()
+	}()
+	<-bLocked
+	time.Sleep(10 * time.Millisecond)
+	if p, _ := a.HolderPriority(); p != 2 {
+		t.Fatalf("holder of a has priority %d, want 2", p)
+	}
+	go func() { // 高优先级任务等待 b，提升沿等待链传递到 a 的持有者
+		defer wg.Done()
+		b.Lock(10)
+		b.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if p, _ := b.HolderPriority(); p != 10 {
+		t.Fatalf("holder of b has priority %d, want 10", p)
+	}
+	if p, _ := a.HolderPriority(); p != 10 {
+		t.Fatalf("holder of a has priority %d, want 10", p)
+	}
+	close(release)
+	wg.Wait()
+	if _, ok := a.HolderPriority(); ok {
+		t.Fatal("a is still locked")
+	}
+}
+
+func TestPriorityMutexOrder(t *testing.T) {
+	var m concurrent.PriorityMutex
+	m.Lock(0)
+	got := make(chan int, 2)
+	for _, p := range []int{1, 5} {
+		go func(p int) {
+			m.Lock(p)
+			got <- p
+			m.Unlock()
+		}(p)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p, _ := m.HolderPriority(); p != 5 {
+		t.Fatalf("holder has priority %d, want 5", p)
+	}
+	m.Unlock()
+	if p := <-got; p != 5 {
+		t.Fatalf("got priority %d first, want 5", p)
+	}
+	<-got
+}
+
+func TestPriorityMutexCancel(t *testing.T) {
+	var m concurrent.PriorityMutex
+	m.Lock(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- m.LockContext(ctx, 7) }()
+	for p, _ := m.HolderPriority(); p != 7; p, _ = m.HolderPriority() { // 等待者已经提升了持有者的优先级
+		runtime.Gosched()
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if p, _ := m.HolderPriority(); p != 0 { // 撤销提升
+		t.Fatalf("holder has priority %d, want 0", p)
+	}
+	m.Unlock()
+}
+
+func TestPriorityMutexUnlockByOther(t *testing.T) {
+	var m concurrent.PriorityMutex
+	m.Lock(0)
+	defer m.Unlock()
+	done := make(chan any)
+	go func() {
+		defer func() { done <- recover() }()
+		m.Unlock()
+	}()
+	if r := <-done; r == nil {
+		t.Fatal("expected panic")
+	}
+}
@@ -0,0 +1,197 @@
+// Package ticker 带抖动和退避的 Ticker，1.basic/time/rand_ticker 中 RandTicker 的完整版本
+//
+// RandTicker 的问题
+//
+//	消费者来不及读取时静默丢弃 tick
+//	随机数源以当前时间为种子，不能复现；时间直接来自 time 包，测试只能真的等待
+//	只有 Stop，不能调整周期
+//
+// Ticker 按照下面的规则计算相邻两个 tick 之间的间隔：
+//
+//	基础间隔：固定为 d；使用 WithBackoff 时为 d*factor^n（n 为 Reset 之后的 tick 数），不超过 max
+//	抖动：WithUniformJitter 在基础间隔上加 [-variance, variance) 的均匀分布；
+//	     WithExponentialJitter 以基础间隔为均值的指数分布，tick 构成泊松过程，适合避免多个采集器同步
+//
+// 间隔从上一个 tick 的计划时间开始算，而不是从它被读取的时间，所以没有抖动时不会累积漂移
+// 与 time.Ticker 一致，C 的容量为 1，消费者来不及读取时丢弃 tick，Dropped 返回丢弃的数量
+package ticker
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+type jitter int
+
+const (
+	noJitter jitter = iota
+	uniformJitter
+	exponentialJitter
+)
+
+type config struct {
+	clock    clock.Clock
+	rnd      *rand.Rand
+	jitter   jitter
+	variance time.Duration
+	factor   float64 // 退避的倍数，0 表示不退避
+	max      time.Duration
+}
+
+// Option Ticker 的选项
+type Option func(*config)
+
+// WithClock 使用 c 作为时钟，默认为 clock.Real
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+// WithRand 使用 rnd 作为随机数源，相同的种子得到相同的抖动，rnd 只会被 Ticker 的 goroutine 使用
+func WithRand(rnd *rand.Rand) Option {
+	return func(cfg *config) { cfg.rnd = rnd }
+}
+
+// WithUniformJitter 间隔在基础间隔上加 [-variance, variance) 的均匀分布，与 RandTicker 一致
+func WithUniformJitter(variance time.Duration) Option {
+	return func(cfg *config) { cfg.jitter, cfg.variance = uniformJitter, variance }
+}
+
+// WithExponentialJitter 间隔服从以基础间隔为均值的指数分布
+func WithExponentialJitter() Option {
+	return func(cfg *config) { cfg.jitter = exponentialJitter }
+}
+
+// WithBackoff 每个 tick 之后基础间隔乘以 factor，最大为 max（<= 0 时不限制），Reset 之后从 d 重新开始
+// 适合失败重试的轮询：失败时继续读取 C，成功后 Reset
+func WithBackoff(factor float64, max time.Duration) Option {
+	return func(cfg *config) { cfg.factor, cfg.max = factor, max }
+}
+
+// Ticker 按照配置的间隔向 C 发送 tick 的计划时间
+type Ticker struct {
+	C <-chan time.Time
+
+	c       chan time.Time
+	cfg     config
+	reset   chan time.Duration
+	resetOK chan struct{} // run 应用了新的间隔之后发送
+	done    chan struct{}
+	stop    sync.Once
+	dropped atomic.Int64
+}
+
+// New 创建一个基础间隔为 d 的 Ticker，d 必须大于 0
+func New(d time.Duration, opts ...Option) *Ticker {
+	if d <= 0 {
+		panic("ticker: non-positive interval for New")
+	}
+	cfg := config{clock: clock.Real}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.rnd == nil {
+		cfg.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	c := make(chan time.Time, 1)
+	t := &Ticker{
+		C:       c,
+		c:       c,
+		cfg:     cfg,
+		reset:   make(chan time.Duration),
+		resetOK: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	// 在返回之前创建第一个 timer，之后 Fake 时钟的 Advance 一定能触发它
+	next := cfg.clock.Now().Add(t.interval(d, 0))
+	go t.run(d, next, cfg.clock.NewTimer(next.Sub(cfg.clock.Now())))
+	return t
+}
+
+// NewRandTicker 与 RandTicker 相同：间隔为 d±variance
+func NewRandTicker(d, variance time.Duration, opts ...Option) *Ticker {
+	return New(d, append(opts, WithUniformJitter(variance))...)
+}
+
+// Reset 把基础间隔改为 d，并从现在开始重新计算，退避也从 d 重新开始
+// Reset 返回之后新的间隔已经生效
+func (t *Ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("ticker: non-positive interval for Reset")
+	}
+	select {
+	case t.reset <- d:
+		<-t.resetOK
+	case <-t.done:
+	}
+}
+
+// Stop 停止 Ticker，与 time.Ticker 一致，不会关闭 C
+func (t *Ticker) Stop() {
+	t.stop.Do(func() { close(t.done) })
+}
+
+// Dropped 消费者来不及读取而丢弃的 tick 数量
+func (t *Ticker) Dropped() int64 { return t.dropped.Load() }
+
+func (t *Ticker) run(d time.Duration, next time.Time, timer clock.Timer) {
+	defer timer.Stop()
+	n := 0 // Reset 之后的 tick 数
+	for {
+		select {
+		case <-timer.C():
+			fired := next
+			n++
+			next = next.Add(t.interval(d, n))
+			for now := t.cfg.clock.Now(); next.Before(now); { // 落后太多，跳过错过的 tick
+				t.dropped.Add(1)
+				n++
+				next = next.Add(t.interval(d, n))
+			}
+			// 先设置下一个 timer 再发送，读到 tick 的消费者可以确定下一个 timer 已经生效
+			timer.Reset(next.Sub(t.cfg.clock.Now()))
+			select {
+			case t.c <- fired:
+			default:
+				t.dropped.Add(1)
+			}
+		case d = <-t.reset:
+			n = 0
+			next = t.cfg.clock.Now().Add(t.interval(d, 0))
+			timer.Reset(next.Sub(t.cfg.clock.Now())) // 已经触发但没有读取的时间被丢弃
+			t.resetOK <- struct{}{}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// interval 第 n 个 tick 之后的间隔
+func (t *Ticker) interval(d time.Duration, n int) time.Duration {
+	base := float64(d)
+	if t.cfg.factor > 0 {
+		base *= math.Pow(t.cfg.factor, float64(n))
+		if t.cfg.max > 0 {
+			base = math.Min(base, float64(t.cfg.max))
+		}
+	}
+	switch t.cfg.jitter {
+	case uniformJitter:
+		if t.cfg.variance > 0 {
+			base += float64(t.cfg.rnd.Int63n(int64(2*t.cfg.variance)) - int64(t.cfg.variance))
+		}
+	case exponentialJitter:
+		base *= t.cfg.rnd.ExpFloat64()
+	}
+	if base < 1 {
+		base = 1
+	}
+	if base >= math.MaxInt64 { // 不限制 max 时指数退避最终会溢出，转换成负数的间隔
+		return math.MaxInt64
+	}
+	return time.Duration(base)
+}
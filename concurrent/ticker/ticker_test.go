@@ -0,0 +1,143 @@
+package ticker
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// expectTick 读取一个 tick，检查它距离 epoch 的时间
+func expectTick(t *testing.T, tk *Ticker, want time.Duration) {
+	t.Helper()
+	select {
+	case at := <-tk.C:
+		if got := at.Sub(epoch); got != want {
+			t.Fatalf("tick at %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no tick, want one at %v", want)
+	}
+}
+
+func expectNoTick(t *testing.T, tk *Ticker) {
+	t.Helper()
+	select {
+	case at := <-tk.C:
+		t.Fatalf("unexpected tick at %v", at.Sub(epoch))
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestFixedRate(t *testing.T) {
+	c := clock.NewFake(epoch)
+	tk := New(time.Second, WithClock(c))
+	defer tk.Stop()
+
+	c.Advance(999 * time.Millisecond)
+	expectNoTick(t, tk)
+	c.Advance(time.Millisecond)
+	expectTick(t, tk, time.Second)
+	c.Advance(time.Second)
+	expectTick(t, tk, 2*time.Second)
+
+	// 没有读取 C 时丢弃，并且计数
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	for deadline := time.Now().Add(time.Second); tk.Dropped() != 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Dropped = %d, want 1", tk.Dropped())
+		}
+	}
+	expectTick(t, tk, 3*time.Second)
+
+	// Reset 之后从现在开始按新的间隔
+	tk.Reset(500 * time.Millisecond)
+	c.Advance(500 * time.Millisecond)
+	expectTick(t, tk, 4500*time.Millisecond)
+}
+
+func TestJitter(t *testing.T) {
+	// 相同的种子得到相同的间隔
+	intervals := func(opts ...Option) []time.Duration {
+		c := clock.NewFake(epoch)
+		tk := New(time.Second, append(opts, WithClock(c), WithRand(rand.New(rand.NewSource(1))))...)
+		defer tk.Stop()
+		var ds []time.Duration
+		last := epoch
+		for len(ds) < 20 {
+			c.BlockUntil(1) // 上一个 tick 之后 timer 已经重置
+			c.Advance(10 * time.Millisecond)
+			select {
+			case at := <-tk.C:
+				ds = append(ds, at.Sub(last))
+				last = at
+			default:
+			}
+		}
+		return ds
+	}
+
+	uniform := intervals(WithUniformJitter(200 * time.Millisecond))
+	same := intervals(WithUniformJitter(200 * time.Millisecond))
+	for i, d := range uniform {
+		if d < 800*time.Millisecond || d >= 1200*time.Millisecond {
+			t.Fatalf("uniform interval %v out of range", d)
+		}
+		if d != same[i] {
+			t.Fatal("same seed, different intervals")
+		}
+	}
+
+	var sum time.Duration
+	for _, d := range intervals(WithExponentialJitter()) {
+		sum += d
+	}
+	if mean := sum / 20; mean < 300*time.Millisecond || mean > 3*time.Second {
+		t.Fatalf("exponential mean %v too far from 1s", mean)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := clock.NewFake(epoch)
+	tk := New(time.Second, WithClock(c), WithBackoff(2, 5*time.Second))
+	defer tk.Stop()
+
+	at := time.Duration(0)
+	for _, d := range []time.Duration{1, 2, 4, 5, 5} {
+		c.Advance(d * time.Second)
+		at += d * time.Second
+		expectTick(t, tk, at)
+	}
+	// Reset 之后重新从 d 开始
+	tk.Reset(time.Second)
+	c.Advance(time.Second)
+	expectTick(t, tk, at+time.Second)
+	c.Advance(time.Second)
+	expectNoTick(t, tk)
+	c.Advance(time.Second)
+	expectTick(t, tk, at+3*time.Second)
+
+	// 不限制 max 时间隔不会溢出
+	unbounded := New(time.Second, WithClock(c), WithBackoff(2, 0))
+	defer unbounded.Stop()
+	for _, n := range []int{10, 40, 63, 64, 1000, 2000} {
+		if d := unbounded.interval(time.Second, n); d <= 0 || d < unbounded.interval(time.Second, n-1) {
+			t.Fatalf("interval(%d) = %v", n, d)
+		}
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	tk := NewRandTicker(10*time.Millisecond, 5*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-tk.C
+	}
+	tk.Stop()
+	tk.Reset(time.Millisecond) // Stop 之后 Reset 不会阻塞
+}
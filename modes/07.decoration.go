@@ -171,7 +171,7 @@ func WithDebugLog(h http.HandlerFunc) http.HandlerFunc {
 }
 func hello(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Recieved Request %s from %s\n", r.URL.Path, r.RemoteAddr)
-	fmt.Fprintf(w, "Hello, World! "+r.URL.Path)
+	fmt.Fprint(w, "Hello, World! "+r.URL.Path)
 }
 
 func main04() {
@@ -184,19 +184,6 @@ func main04() {
 	}
 }
 
-func WithServerHeader(h http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		log.Println("--->WithServerHeader()")
-		w.Header().Set("Server", "HelloServer v0.0.1")
-		h(w, r)
-	}
-}
-
-func hello(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Recieved Request %s from %s\n", r.URL.Path, r.RemoteAddr)
-	fmt.Fprintf(w, "Hello, World! "+r.URL.Path)
-}
-
 func main03() {
 	http.HandleFunc("/v1/hello", WithServerHeader(hello))
 	err := http.ListenAndServe(":8080", nil)
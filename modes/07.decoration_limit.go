@@ -0,0 +1,174 @@
+package modes
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang/concurrent/ratelimit"
+)
+
+/*
+限流和过载保护的修饰器
+	与客户端协商（begin/05&09.architectural/面向恢复的设计.go）
+		服务器：“我太忙了，请慢点发送数据”，拒绝的请求返回 429 或 503，并通过 Retry-After 告诉客户端多久之后再试
+		Client：“好，我一分钟后再发送”，而不是立即重试，把服务器压得更垮
+	WithRateLimit
+		按 key（客户端 IP、API Key 等）限流，超过速率的请求返回 429 Too Many Requests
+		限流器是令牌桶时，Retry-After 为还需要等待的时间
+	WithConcurrencyLimit
+		同时处理的请求数不超过 n，超过的请求立即返回 503 Service Unavailable，而不是排队拖慢所有请求
+	WithLoadShedding
+		固定的并发数很难设置：太小浪费资源，太大时下游变慢、请求堆积，延迟越来越高
+		LoadShedder 根据请求的延迟自适应地调整并发数的上限（gradient 算法，类似 Netflix concurrency-limits 的 Gradient2）
+			longRTT：长期的平均延迟，作为基准；shortRTT：最近的平均延迟
+			gradient = 1.5 * longRTT / shortRTT，限制在 [0.5, 1]，最近的延迟明显升高时小于 1，上限随之降低
+			上限 = limit*gradient + sqrt(limit)，延迟不变时慢慢试探更高的并发
+			延迟持续升高时 longRTT 也会慢慢跟上，上限不会一直停在最低
+		超过上限的请求返回 503
+	使用
+		http.HandleFunc("/v5/hello", Handler(hello,
+			WithServerHeader, WithRateLimit(ClientIP, limiter), WithLoadShedding(NewLoadShedder(20, 200))))
+*/
+
+// ====================限流和过载保护的修饰器====================
+
+// ClientIP 以客户端的 IP 作为限流的 key
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// WithRateLimit 按 keyFunc 返回的 key 限流，超过速率时返回 429
+func WithRateLimit(keyFunc func(*http.Request) string, limiter *ratelimit.Keyed[string]) HttpHandlerDecorator {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			lim := limiter.Limiter(keyFunc(r))
+			if tb, ok := lim.(*ratelimit.TokenBucket); ok { // 令牌桶可以算出需要等待多久
+				if res := tb.Reserve(); res.Delay() > 0 {
+					res.Cancel()
+					reject(w, http.StatusTooManyRequests, res.Delay())
+					return
+				}
+			} else if !lim.Allow() {
+				reject(w, http.StatusTooManyRequests, time.Second)
+				return
+			}
+			h(w, r)
+		}
+	}
+}
+
+// WithConcurrencyLimit 同时最多处理 n 个请求，超过时返回 503
+func WithConcurrencyLimit(n int) HttpHandlerDecorator {
+	sema := make(chan struct{}, n)
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sema <- struct{}{}:
+				defer func() { <-sema }()
+				h(w, r)
+			default:
+				reject(w, http.StatusServiceUnavailable, time.Second)
+			}
+		}
+	}
+}
+
+// WithLoadShedding 并发数超过 s 自适应的上限时返回 503
+func WithLoadShedding(s *LoadShedder) HttpHandlerDecorator {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			done, ok := s.Acquire()
+			if !ok {
+				reject(w, http.StatusServiceUnavailable, time.Second)
+				return
+			}
+			defer done()
+			h(w, r)
+		}
+	}
+}
+
+// reject 拒绝请求，Retry-After 为整数秒，至少为 1
+func reject(w http.ResponseWriter, code int, retryAfter time.Duration) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, http.StatusText(code), code)
+}
+
+// LoadShedder 根据延迟自适应调整并发上限的过载保护
+type LoadShedder struct {
+	mu       sync.Mutex
+	limit    float64
+	max      float64
+	inflight int
+	longRTT  float64 // 长期的平均延迟，纳秒
+	shortRTT float64 // 最近的平均延迟，纳秒
+}
+
+// NewLoadShedder 并发上限从 initial 开始，在 [1, max] 之间调整
+func NewLoadShedder(initial, max int) *LoadShedder {
+	return &LoadShedder{limit: float64(initial), max: float64(max)}
+}
+
+// Acquire 请求处理一个请求，超过上限时返回 false；成功时处理完调用 done
+func (s *LoadShedder) Acquire() (done func(), ok bool) {
+	s.mu.Lock()
+	if s.inflight >= int(s.limit) {
+		s.mu.Unlock()
+		return nil, false
+	}
+	s.inflight++
+	inflight := s.inflight
+	s.mu.Unlock()
+
+	start := time.Now()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.inflight--
+		s.sample(time.Since(start), inflight)
+	}, true
+}
+
+// Limit 当前的并发上限
+func (s *LoadShedder) Limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.limit)
+}
+
+const (
+	shedderSmoothing  = 0.2  // 上限调整的平滑系数
+	shedderLongDecay  = 0.01 // longRTT 指数移动平均的系数
+	shedderShortDecay = 0.1  // shortRTT 指数移动平均的系数
+	shedderTolerance  = 1.5  // 最近的延迟不超过基准的 1.5 倍时不降低上限
+)
+
+// sample 根据一个请求的延迟调整上限，inflight 为这个请求开始时的并发数，需要持有 s.mu
+func (s *LoadShedder) sample(rtt time.Duration, inflight int) {
+	x := math.Max(1, float64(rtt))
+	if s.longRTT == 0 {
+		s.longRTT, s.shortRTT = x, x
+	} else {
+		s.longRTT += shedderLongDecay * (x - s.longRTT)
+		s.shortRTT += shedderShortDecay * (x - s.shortRTT)
+	}
+
+	gradient := math.Max(0.5, math.Min(1, shedderTolerance*s.longRTT/s.shortRTT)) // 每次最多减半
+	if gradient == 1 && float64(inflight) < s.limit/2 {
+		return // 负载不高，没有试探的必要
+	}
+	next := s.limit*gradient + math.Sqrt(s.limit)
+	s.limit = math.Max(1, math.Min(s.max, s.limit*(1-shedderSmoothing)+next*shedderSmoothing))
+}
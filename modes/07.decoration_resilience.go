@@ -0,0 +1,84 @@
+package modes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang/concurrent/resilience"
+)
+
+/*
+熔断和舱壁的修饰器
+	面向错误的设计（begin/05&09.architectural/面向错误的设计.go）
+		错误传递：下游出故障时，上游的请求一直等待超时，最终整条调用链都被拖垮，需要断路器
+		隔离：一个变慢的依赖不能占满所有的 goroutine 和连接，需要舱壁
+	WithCircuitBreaker
+		handler 返回 5xx 或者 panic 算作失败，失败率超过阈值时熔断器打开
+		打开时直接返回 503，Retry-After 为熔断器进入半开状态还需要的时间
+	WithBulkhead
+		同时处理的请求数不超过舱壁的上限，已满时最多等待 MaxWait，之后返回 503，Retry-After 为 MaxWait，至少 1s
+		与 WithConcurrencyLimit 不同，舱壁按依赖划分，多个路由访问同一个依赖时共享一个 Bulkhead
+	使用
+		db, _ := resilience.NewBulkhead(resilience.BulkheadConfig{MaxConcurrent: 20, MaxWait: 100 * time.Millisecond})
+		cb, _ := resilience.NewCircuitBreaker(resilience.BreakerConfig{Name: "db"})
+		http.HandleFunc("/v6/user", Handler(user, WithCircuitBreaker(cb), WithBulkhead(db)))
+	在 handler 之外调用依赖时，直接使用 resilience.Do、resilience.Wrap 包装函数
+*/
+
+// ====================熔断和舱壁的修饰器====================
+
+// WithCircuitBreaker 熔断器打开时返回 503，handler 的 5xx 响应算作失败
+func WithCircuitBreaker(cb *resilience.CircuitBreaker) HttpHandlerDecorator {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			done, err := cb.Acquire(r.Context())
+			if err != nil {
+				reject(w, http.StatusServiceUnavailable, cb.RetryAfter())
+				return
+			}
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			failed := true // handler panic 时也算作失败
+			defer func() {
+				if failed {
+					done(errServerError)
+				} else {
+					done(nil)
+				}
+			}()
+			h(rec, r)
+			failed = rec.status >= http.StatusInternalServerError
+		}
+	}
+}
+
+// WithBulkhead 舱壁已满且等待超时时返回 503
+func WithBulkhead(b *resilience.Bulkhead) HttpHandlerDecorator {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			done, err := b.Acquire(r.Context())
+			if err != nil {
+				reject(w, http.StatusServiceUnavailable, b.RetryAfter())
+				return
+			}
+			defer done(nil)
+			h(w, r)
+		}
+	}
+}
+
+// errServerError 报告给熔断器的失败，handler 本身不返回 error
+var errServerError = errors.New("modes: handler failed")
+
+// statusRecorder 记录 handler 写入的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap 让 http.ResponseController 可以访问原来的 ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,179 @@
+package modes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang/concurrent/clock"
+	"golang/concurrent/ratelimit"
+	"golang/concurrent/resilience"
+)
+
+func serve(h http.HandlerFunc, remoteAddr string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	r.RemoteAddr = remoteAddr
+	h(w, r)
+	return w
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+func TestWithRateLimit(t *testing.T) {
+	limiter := ratelimit.NewKeyed(func(string) ratelimit.Limiter {
+		return ratelimit.NewTokenBucket(0.5, 2) // 每 2s 一个令牌
+	}, time.Minute)
+	h := Handler(okHandler, WithRateLimit(ClientIP, limiter))
+
+	for i := 0; i < 2; i++ {
+		if w := serve(h, "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i, w.Code)
+		}
+	}
+	w := serve(h, "10.0.0.1:5678") // 同一个 IP
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("code = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(h, "10.0.0.2:1234"); w.Code != http.StatusOK { // 其它客户端不受影响
+		t.Fatalf("other client = %d", w.Code)
+	}
+}
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := Handler(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}, WithConcurrencyLimit(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(h, "10.0.0.1:1")
+		}()
+		<-entered
+	}
+	w := serve(h, "10.0.0.1:1")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("code = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(release)
+	wg.Wait()
+	go func() { <-entered }()
+	if w := serve(h, "10.0.0.1:1"); w.Code != http.StatusOK {
+		t.Fatalf("after release = %d", w.Code)
+	}
+}
+
+func TestLoadShedder(t *testing.T) {
+	s := NewLoadShedder(10, 100)
+
+	// 延迟稳定且负载高时，上限慢慢升高
+	for i := 0; i < 200; i++ {
+		s.sample(10*time.Millisecond, s.Limit())
+	}
+	if got := s.Limit(); got < 50 {
+		t.Fatalf("limit = %d after stable latency, want growth", got)
+	}
+	high := s.Limit()
+
+	// 延迟升高到 4 倍，上限降低
+	for i := 0; i < 30; i++ {
+		s.sample(40*time.Millisecond, s.Limit())
+	}
+	if got := s.Limit(); got >= high/2 {
+		t.Fatalf("limit = %d after latency spike, was %d", got, high)
+	}
+
+	// 负载不高时不试探
+	low := NewLoadShedder(10, 100)
+	for i := 0; i < 100; i++ {
+		low.sample(10*time.Millisecond, 1)
+	}
+	if got := low.Limit(); got != 10 {
+		t.Fatalf("limit = %d with low load", got)
+	}
+
+	// 超过上限的请求被拒绝
+	one := NewLoadShedder(1, 1)
+	done, ok := one.Acquire()
+	if !ok {
+		t.Fatal("first Acquire failed")
+	}
+	if _, ok := one.Acquire(); ok {
+		t.Fatal("Acquire over limit succeeded")
+	}
+	w := serve(Handler(okHandler, WithLoadShedding(one)), "10.0.0.1:1")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("code = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	done()
+	if w := serve(Handler(okHandler, WithLoadShedding(one)), "10.0.0.1:1"); w.Code != http.StatusOK {
+		t.Fatalf("after done = %d", w.Code)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	cb, _ := resilience.NewCircuitBreaker(resilience.BreakerConfig{MinRequests: 2, OpenTimeout: 10 * time.Second, Clock: c})
+	failing := true
+	h := Handler(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}, WithCircuitBreaker(cb))
+
+	serve(h, "10.0.0.1:1")
+	serve(h, "10.0.0.1:1")
+	failing = false
+	w := serve(h, "10.0.0.1:1") // 两个 5xx 之后熔断器打开，不再调用 handler
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "10" {
+		t.Fatalf("code = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	c.Advance(10 * time.Second)
+	if w := serve(h, "10.0.0.1:1"); w.Code != http.StatusOK || cb.State() != resilience.Closed {
+		t.Fatalf("probe = %d, state = %v", w.Code, cb.State())
+	}
+}
+
+func TestWithBulkhead(t *testing.T) {
+	b, _ := resilience.NewBulkhead(resilience.BulkheadConfig{MaxConcurrent: 1})
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := Handler(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}, WithBulkhead(b))
+
+	done := make(chan struct{})
+	go func() {
+		serve(h, "10.0.0.1:1")
+		close(done)
+	}()
+	<-entered
+	if w := serve(h, "10.0.0.1:1"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("code = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(release)
+	<-done
+
+	// 等待 MaxWait 之后拒绝，Retry-After 为 MaxWait
+	c := clock.NewFake(time.Unix(0, 0))
+	b, _ = resilience.NewBulkhead(resilience.BulkheadConfig{MaxConcurrent: 1, MaxWait: 2500 * time.Millisecond, Clock: c})
+	hold, _ := b.Acquire(context.Background())
+	defer hold(nil)
+	rejected := make(chan *httptest.ResponseRecorder)
+	go func() { rejected <- serve(Handler(okHandler, WithBulkhead(b)), "10.0.0.1:1") }()
+	c.BlockUntil(1)
+	c.Advance(2500 * time.Millisecond)
+	if w := <-rejected; w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "3" {
+		t.Fatalf("code = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}